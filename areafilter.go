@@ -0,0 +1,225 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+	"strings"
+)
+
+// A geographic area, e.g. a gtfs.BBox or a gtfs.Polygon
+type Area interface {
+	Contains(lat float64, lon float64) bool
+}
+
+// Keeps only the stops inside an area
+type AreaFilter struct {
+	Area Area
+
+	// if set, trips leaving the area are truncated to their longest
+	// part inside the area, otherwise they are removed
+	TruncateTrips bool
+
+	// if set, the shape of each trip is cut to the part between its
+	// first and last stop, so shapes end at the area's border
+	ClipShapes bool
+}
+
+// Create a new AreaFilter which removes trips leaving the area
+func NewAreaFilter(area Area) *AreaFilter {
+	return &AreaFilter{Area: area}
+}
+
+func (f *AreaFilter) Apply(feed *Feed) *Removed {
+	removed := &Removed{}
+	before := feed.getUsed()
+
+	inside := make(map[*gtfs.Stop]bool)
+	for _, stop := range feed.Stops {
+		if f.Area.Contains(float64(stop.Lat), float64(stop.Lon)) {
+			inside[stop] = true
+		}
+	}
+
+	for _, trip := range feed.Trips {
+		first, last := getLongestRunInside(trip, inside)
+
+		if last-first+1 < 2 || (!f.TruncateTrips && last-first+1 < len(trip.StopTimes)) {
+			feed.removeTrip(trip, removed)
+			continue
+		}
+
+		// the first and last stop time of a trip must be timed
+		for first < last && !isTimed(trip.StopTimes[first]) {
+			first++
+		}
+		for last > first && !isTimed(trip.StopTimes[last]) {
+			last--
+		}
+		if last-first+1 < 2 {
+			feed.removeTrip(trip, removed)
+			continue
+		}
+
+		if first > 0 {
+			shiftFrequencies(trip, trip.StopTimes[first])
+		}
+
+		trip.StopTimes = trip.StopTimes[first : last+1]
+	}
+
+	// parent stations of kept stops are kept, even if outside the area
+	keep := make(map[*gtfs.Stop]bool)
+	for stop := range inside {
+		keep[stop] = true
+		if parent, ok := feed.Stops[stop.Parent_station]; ok {
+			keep[parent] = true
+		}
+	}
+
+	for _, stop := range feed.Stops {
+		if !keep[stop] {
+			feed.removeStop(stop, removed)
+		}
+	}
+
+	// replaced shapes are removed by the cascade
+	if f.ClipShapes {
+		feed.cutShapesToTrips()
+	}
+
+	feed.removeOrphans(before, removed)
+
+	return removed
+}
+
+func isTimed(st *gtfs.StopTime) bool {
+	_, arrErr := gtfs.ParseTime(st.Arrival_time)
+	_, depErr := gtfs.ParseTime(st.Departure_time)
+	return arrErr == nil || depErr == nil
+}
+
+// Shift the frequencies of a trip which will start at stop time first
+// instead of its current first stop time, so all instances still pass
+// the remaining stops at the same times
+func shiftFrequencies(trip *gtfs.Trip, first *gtfs.StopTime) {
+	if len(trip.Frequencies) == 0 {
+		return
+	}
+
+	oldDep, err := getFirstDeparture(trip)
+	if err != nil {
+		return
+	}
+
+	newDep, err := gtfs.ParseTime(first.Departure_time)
+	if err != nil {
+		if newDep, err = gtfs.ParseTime(first.Arrival_time); err != nil {
+			return
+		}
+	}
+
+	offset := newDep - oldDep
+	freqs := make([]*gtfs.Frequency, 0, len(trip.Frequencies))
+
+	for _, f := range trip.Frequencies {
+		start, err1 := gtfs.ParseTime(f.Start_time)
+		end, err2 := gtfs.ParseTime(f.End_time)
+		if err1 != nil || err2 != nil {
+			freqs = append(freqs, f)
+			continue
+		}
+
+		shifted := *f
+		shifted.Start_time = gtfs.FormatTime(start + offset)
+		shifted.End_time = gtfs.FormatTime(end + offset)
+		freqs = append(freqs, &shifted)
+	}
+
+	trip.Frequencies = freqs
+}
+
+// Get the first and last index of the longest run of consecutive stop
+// times inside the area. Returns last < first if there is none.
+func getLongestRunInside(trip *gtfs.Trip, inside map[*gtfs.Stop]bool) (int, int) {
+	bestFirst, bestLast := 0, -1
+	first := -1
+
+	for i, st := range trip.StopTimes {
+		if !inside[st.Stop] {
+			first = -1
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		if i-first > bestLast-bestFirst {
+			bestFirst, bestLast = first, i
+		}
+	}
+
+	return bestFirst, bestLast
+}
+
+// Cut the shape of each trip to the part between its first and last
+// stop. Trips with the same shape and stops share the cut shape, which
+// keeps the original ID for the first variant of each shape.
+func (feed *Feed) cutShapesToTrips() {
+	tripIds := make([]string, 0, len(feed.Trips))
+	for id := range feed.Trips {
+		tripIds = append(tripIds, id)
+	}
+	sort.Strings(tripIds)
+
+	cut := make(map[string]*gtfs.Shape)
+	variants := make(map[*gtfs.Shape]int)
+
+	for _, id := range tripIds {
+		trip := feed.Trips[id]
+		if trip.Shape == nil || len(trip.StopTimes) < 2 {
+			continue
+		}
+
+		stops := make([]*gtfs.Stop, len(trip.StopTimes))
+		stopIds := make([]string, len(trip.StopTimes))
+		for i, st := range trip.StopTimes {
+			stops[i] = st.Stop
+			stopIds[i] = st.Stop.Id
+		}
+
+		key := trip.Shape.Id + "\x00" + strings.Join(stopIds, "\x00")
+		if shape, ok := cut[key]; ok {
+			trip.Shape = shape
+			continue
+		}
+
+		// keep the whole shape if the stops cannot be projected onto it
+		// in order
+		from, to := 0.0, trip.Shape.Points.Length()
+		pos, _ := trip.Shape.ProjectStops(stops, maxStopShapeDist)
+		if pos[len(pos)-1] > pos[0] {
+			from, to = pos[0], pos[len(pos)-1]
+		}
+
+		shapeId := trip.Shape.Id
+		if variants[trip.Shape] > 0 {
+			for n := variants[trip.Shape] + 1; feed.Shapes[shapeId] != nil; n++ {
+				shapeId = fmt.Sprintf("%s_%d", trip.Shape.Id, n)
+			}
+		}
+		variants[trip.Shape]++
+
+		shape := &gtfs.Shape{Id: shapeId, Points: trip.Shape.Points.Cut(from, to)}
+		feed.Shapes[shapeId] = shape
+		cut[key] = shape
+		trip.Shape = shape
+	}
+
+	feed.InvalidateIndex()
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"testing"
+)
+
+func TestAreaFilter(t *testing.T) {
+	feed := newTestFeed()
+
+	// T1 and T2 both leave the area, so everything they use is removed
+	removed := feed.Filter(NewAreaFilter(gtfs.BBox{MinLat: 46.9, MinLon: 6.9, MaxLat: 47.1, MaxLon: 7.15}))
+
+	checkIds(t, "trips", getIds(feed.Trips))
+	checkIds(t, "removed trips", removed.Trips, "T1", "T2")
+	checkIds(t, "removed routes", removed.Routes, "R1", "R2")
+	checkIds(t, "removed services", removed.Services, "SAT", "WEEK")
+	checkIds(t, "removed shapes", removed.Shapes, "SH1")
+	checkIds(t, "removed stops", removed.Stops, "S1", "S2", "S3", "S4")
+	checkIds(t, "removed agencies", removed.Agencies, "A2")
+	checkIds(t, "removed fare attributes", removed.FareAttributes, "F1", "F2")
+
+	// entities which were unused before are kept
+	checkIds(t, "agencies", getIds(feed.Agencies), "A1", "AX")
+	checkIds(t, "stops", getIds(feed.Stops), "SX")
+	checkIds(t, "routes", getIds(feed.Routes), "RX")
+	checkIds(t, "services", getIds(feed.Services), "UNUSED")
+	checkIds(t, "shapes", getIds(feed.Shapes), "SHX")
+	checkIds(t, "fare attributes", getIds(feed.FareAttributes), "FX")
+
+	if removed.Transfers != 1 || len(feed.Transfers) != 0 {
+		t.Errorf("got %d removed transfers, want 1", removed.Transfers)
+	}
+}
+
+func TestAreaFilterTruncate(t *testing.T) {
+	feed := newTestFeed()
+	shape := feed.Shapes["SH1"]
+
+	f := NewAreaFilter(gtfs.BBox{MinLat: 46.9, MinLon: 7.05, MaxLat: 47.1, MaxLon: 7.35})
+	f.TruncateTrips = true
+	f.ClipShapes = true
+	removed := feed.Filter(f)
+
+	checkIds(t, "trips", getIds(feed.Trips), "T1", "T2")
+	checkIds(t, "removed stops", removed.Stops, "S1")
+	checkIds(t, "removed routes", removed.Routes)
+	checkIds(t, "removed shapes", removed.Shapes)
+
+	trip := feed.Trips["T1"]
+	if len(trip.StopTimes) != 2 || trip.StopTimes[0].Stop.Id != "S2" {
+		t.Fatalf("got %d stop times, want 2 starting at S2", len(trip.StopTimes))
+	}
+
+	// the frequencies are shifted to the new first stop, so the trips
+	// still depart S2 at the same times
+	if fr := trip.Frequencies[0]; fr.Start_time != "08:10:00" || fr.End_time != "09:10:00" {
+		t.Errorf("got frequency from %s to %s, want from 08:10:00 to 09:10:00", fr.Start_time, fr.End_time)
+	}
+
+	// the shape is cut to the part between S2 and S3
+	if trip.Shape == shape || trip.Shape.Id != "SH1" || feed.Shapes["SH1"] != trip.Shape {
+		t.Fatalf("got shape %v, want a cut shape replacing SH1", trip.Shape.Id)
+	}
+	want := gtfs.HaversineDist(47, 7.1, 47, 7.2)
+	if l := trip.Shape.Points.Length(); l < want-1 || l > want+1 {
+		t.Errorf("got shape length %f, want %f", l, want)
+	}
+}
+
+func TestAreaFilterKeepsUnprojectableShapes(t *testing.T) {
+	feed := newTestFeed()
+
+	// T3 runs against the direction of SH1, so its stops cannot be
+	// projected onto it in order
+	addTrip(feed, "T3", "R1", "WEEK", "S3", "10:00:00", "S2", "10:10:00")
+	feed.Trips["T3"].Shape = feed.Shapes["SH1"]
+
+	f := NewAreaFilter(gtfs.BBox{MinLat: 46.9, MinLon: 7.05, MaxLat: 47.1, MaxLon: 7.35})
+	f.TruncateTrips = true
+	f.ClipShapes = true
+	feed.Filter(f)
+
+	shape := feed.Trips["T3"].Shape
+	if shape == nil {
+		t.Fatal("got no shape for T3")
+	}
+	if l := shape.Points.Length(); l < feed.Trips["T1"].Shape.Points.Length() {
+		t.Errorf("got shape length %f for T3, want the whole shape", l)
+	}
+	if feed.Shapes[shape.Id] != shape {
+		t.Errorf("shape %s of T3 is not contained in the feed", shape.Id)
+	}
+}
@@ -56,7 +56,7 @@ func (p *CsvParser) ParseCsvLine() []string {
 	if err == io.EOF {
 		return nil
 	} else if err != nil {
-		panic(err.Error())
+		panic(err)
 	}
 	return record
 }
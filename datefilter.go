@@ -0,0 +1,100 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+)
+
+// Keeps only service between two dates, inclusive
+type DateFilter struct {
+	Start gtfs.Date
+	End   gtfs.Date
+}
+
+// Create a new DateFilter for the given window
+func NewDateFilter(start gtfs.Date, end gtfs.Date) *DateFilter {
+	return &DateFilter{Start: start, End: end}
+}
+
+// Create a new DateFilter for the n days starting at date start
+func NewDateFilterDays(start gtfs.Date, n int) *DateFilter {
+	return &DateFilter{Start: start, End: start.AddDays(n - 1)}
+}
+
+func (f *DateFilter) Apply(feed *Feed) *Removed {
+	removed := &Removed{}
+	before := feed.getUsed()
+
+	inactive := make(map[*gtfs.Service]bool)
+	for _, service := range feed.Services {
+		f.clipService(service)
+		if service.GetFirstActiveDate().IsEmpty() {
+			inactive[service] = true
+		}
+	}
+
+	for _, trip := range feed.Trips {
+		if inactive[trip.Service] {
+			feed.removeTrip(trip, removed)
+		}
+	}
+
+	// services without trips are removed by the cascade, services
+	// which are never active have to be removed explicitly
+	for id, service := range feed.Services {
+		if inactive[service] {
+			delete(feed.Services, id)
+			removed.Services = append(removed.Services, id)
+		}
+	}
+
+	for _, fi := range feed.FeedInfos {
+		f.clipFeedInfo(fi)
+	}
+
+	feed.removeOrphans(before, removed)
+
+	return removed
+}
+
+// Clip the calendar range and the exceptions of a service to the window
+func (f *DateFilter) clipService(s *gtfs.Service) {
+	if !s.Start_date.IsEmpty() && !s.End_date.IsEmpty() {
+		if s.Start_date.Before(f.Start) {
+			s.Start_date = f.Start
+		}
+		if s.End_date.After(f.End) {
+			s.End_date = f.End
+		}
+
+		// the calendar does not overlap the window
+		if s.Start_date.After(s.End_date) {
+			s.Daymap = [7]bool{}
+			s.Start_date = f.Start
+			s.End_date = f.End
+		}
+	}
+
+	exceptions := make([]*gtfs.ServiceException, 0, len(s.Exceptions))
+	for _, e := range s.Exceptions {
+		if !e.Date.Before(f.Start) && !e.Date.After(f.End) {
+			exceptions = append(exceptions, e)
+		}
+	}
+	s.Exceptions = exceptions
+}
+
+// Clip the validity window of a feed info to the window
+func (f *DateFilter) clipFeedInfo(fi *gtfs.FeedInfo) {
+	if !fi.Start_date.IsEmpty() && fi.Start_date.Before(f.Start) {
+		fi.Start_date = f.Start
+	}
+	if !fi.End_date.IsEmpty() && fi.End_date.After(f.End) {
+		fi.End_date = f.End
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"testing"
+)
+
+func TestDateFilter(t *testing.T) {
+	feed := newTestFeed()
+	sat := gtfs.Date{Day: 6, Month: 6, Year: 2020}
+	feed.Services["SAT"].Exceptions = []*gtfs.ServiceException{
+		{Date: sat, Type: 2},
+		{Date: gtfs.Date{Day: 1, Month: 8, Year: 2020}, Type: 2},
+	}
+
+	// the first two weekends of June 2020, without service on the first
+	// saturday
+	removed := feed.Filter(NewDateFilterDays(sat, 9))
+
+	checkIds(t, "trips", getIds(feed.Trips), "T1", "T2")
+	checkIds(t, "removed trips", removed.Trips)
+
+	s := feed.Services["SAT"]
+	if s.Start_date != sat || s.End_date != sat.AddDays(8) {
+		t.Errorf("got calendar from %v to %v, want from %v to %v", s.Start_date, s.End_date, sat, sat.AddDays(8))
+	}
+	if len(s.Exceptions) != 1 {
+		t.Errorf("got %d exceptions, want 1", len(s.Exceptions))
+	}
+
+	// only the first saturday
+	feed = newTestFeed()
+	feed.Services["SAT"].Exceptions = []*gtfs.ServiceException{{Date: sat, Type: 2}}
+	removed = feed.Filter(NewDateFilterDays(sat, 2))
+
+	checkIds(t, "removed trips", removed.Trips, "T1", "T2")
+	checkIds(t, "removed services", removed.Services, "SAT", "WEEK")
+	checkIds(t, "removed routes", removed.Routes, "R1", "R2")
+	checkIds(t, "removed shapes", removed.Shapes, "SH1")
+	checkIds(t, "removed stops", removed.Stops, "S1", "S2", "S3", "S4")
+
+	// entities which were unused before are kept
+	checkIds(t, "services", getIds(feed.Services), "UNUSED")
+	checkIds(t, "routes", getIds(feed.Routes), "RX")
+	checkIds(t, "stops", getIds(feed.Stops), "SX")
+	checkIds(t, "shapes", getIds(feed.Shapes), "SHX")
+	checkIds(t, "agencies", getIds(feed.Agencies), "A1", "AX")
+}
@@ -0,0 +1,160 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"errors"
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+	"time"
+)
+
+// A single departure of a trip at a stop
+type Departure struct {
+	Time        time.Time
+	Trip        *gtfs.Trip
+	Route       *gtfs.Route
+	StopTime    *gtfs.StopTime
+	Headsign    string
+	Pickup_type int
+}
+
+// Get all departures at a stop between from (inclusive) and from+window
+// (exclusive), sorted by time. Trips of previous service days still
+// running after midnight and frequency-based trips are included. Trips
+// ending at the stop are not.
+func (feed *Feed) Departures(stop *gtfs.Stop, from time.Time, window time.Duration) []Departure {
+	ret := make([]Departure, 0)
+	to := from.Add(window)
+	locs := make(map[*gtfs.Agency]*time.Location)
+
+	for _, tst := range feed.StopTimesAtStop(stop) {
+		trip, st := tst.Trip, tst.StopTime
+
+		if st == trip.StopTimes[len(trip.StopTimes)-1] {
+			continue
+		}
+
+		dep, err := gtfs.ParseTime(st.Departure_time)
+		if err != nil {
+			if dep, err = gtfs.ParseTime(st.Arrival_time); err != nil {
+				continue
+			}
+		}
+
+		// the departures of this stop time, relative to the service day
+		deps := []int{dep}
+		if len(trip.Frequencies) > 0 {
+			deps = getFrequencyDepartures(trip, dep)
+		}
+
+		if len(deps) == 0 {
+			continue
+		}
+
+		loc := feed.getLocation(trip.Route, locs)
+		headsign := trip.Headsign
+		if len(st.Headsign) > 0 {
+			headsign = st.Headsign
+		}
+
+		// service days which may have departures in the window
+		first := gtfs.GetDate(from.In(loc)).AddDays(-(deps[len(deps)-1]/86400 + 1))
+		last := gtfs.GetDate(to.In(loc))
+
+		for d := first; !d.After(last); d = d.AddDays(1) {
+			if !trip.Service.IsActiveOn(d) {
+				continue
+			}
+
+			base := getServiceDayStart(d, loc)
+
+			for _, secs := range deps {
+				t := base.Add(time.Duration(secs) * time.Second)
+				if t.Before(from) || !t.Before(to) {
+					continue
+				}
+				ret = append(ret, Departure{
+					Time:        t,
+					Trip:        trip,
+					Route:       trip.Route,
+					StopTime:    st,
+					Headsign:    headsign,
+					Pickup_type: st.Pickup_type,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if !ret[i].Time.Equal(ret[j].Time) {
+			return ret[i].Time.Before(ret[j].Time)
+		}
+		return ret[i].Trip.Id < ret[j].Trip.Id
+	})
+
+	return ret
+}
+
+// Get the departures of all instances of a frequency-based trip at a
+// stop the trip template departs from at dep, sorted by time
+func getFrequencyDepartures(trip *gtfs.Trip, dep int) []int {
+	start, err := getFirstDeparture(trip)
+	if err != nil {
+		return nil
+	}
+
+	ret := make([]int, 0)
+	for _, f := range trip.Frequencies {
+		for _, t := range f.GetStartTimes() {
+			ret = append(ret, t+dep-start)
+		}
+	}
+
+	sort.Ints(ret)
+
+	return ret
+}
+
+// Get the departure time of a trip at its first stop
+func getFirstDeparture(trip *gtfs.Trip) (int, error) {
+	if len(trip.StopTimes) == 0 {
+		return 0, errors.New("Trip " + trip.Id + " has no stop times")
+	}
+
+	first := trip.StopTimes[0]
+	if dep, err := gtfs.ParseTime(first.Departure_time); err == nil {
+		return dep, nil
+	}
+	return gtfs.ParseTime(first.Arrival_time)
+}
+
+// Get the reference time of a service day, which is noon minus 12h.
+// This differs from midnight on days with daylight saving time changes.
+func getServiceDayStart(d gtfs.Date, loc *time.Location) time.Time {
+	return time.Date(int(d.Year), time.Month(d.Month), int(d.Day), 12, 0, 0, 0, loc).Add(-12 * time.Hour)
+}
+
+// Get the timezone the times of a route's trips are given in
+func (feed *Feed) getLocation(route *gtfs.Route, cache map[*gtfs.Agency]*time.Location) *time.Location {
+	agency := feed.getRouteAgency(route)
+	if agency == nil {
+		return time.UTC
+	}
+
+	if loc, ok := cache[agency]; ok {
+		return loc
+	}
+
+	loc, err := time.LoadLocation(agency.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	cache[agency] = loc
+
+	return loc
+}
@@ -29,6 +29,13 @@ type Feed struct {
 
 	zipFileCloser *zip.ReadCloser
 	curFileHandle *os.File
+
+	// line of the first record of each entity, per file
+	lines map[string]map[string]int
+
+	// reverse and spatial indexes, built on demand
+	index        *feedIndex
+	spatialIndex *spatialIndex
 }
 
 // Create a new, empty feed
@@ -43,6 +50,7 @@ func NewFeed() *Feed {
 		Shapes:         make(map[string]*gtfs.Shape),
 		Transfers:      make([]*gtfs.Transfer, 0),
 		FeedInfos:      make([]*gtfs.FeedInfo, 0),
+		lines:          make(map[string]map[string]int),
 	}
 	return &g
 }
@@ -99,6 +107,9 @@ func (feed *Feed) Parse(path string) error {
 		sort.Sort(shape.Points)
 	}
 
+	// a previously built index does not cover the new entities
+	feed.InvalidateIndex()
+
 	// close open readers
 	if feed.zipFileCloser != nil {
 		feed.zipFileCloser.Close()
@@ -111,6 +122,22 @@ func (feed *Feed) Parse(path string) error {
 	return e
 }
 
+// Get the line of the first record of an entity in a file, or 0
+// if the entity was not read from that file. Fare rules are located
+// by their fare ID, transfers by their from_stop_id.
+func (feed *Feed) GetLine(filename string, id string) int {
+	return feed.lines[filename][id]
+}
+
+func (feed *Feed) setLine(filename string, id string, line int) {
+	if feed.lines[filename] == nil {
+		feed.lines[filename] = make(map[string]int)
+	}
+	if _, ok := feed.lines[filename][id]; !ok {
+		feed.lines[filename][id] = line
+	}
+}
+
 func (feed *Feed) getFile(path string, name string) (io.Reader, error) {
 	fileInfo, err := os.Stat(path)
 
@@ -155,17 +182,19 @@ func (feed *Feed) parseAgencies(path string) (err error) {
 
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"agency.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "agency.txt", reader.Curline, record["agency_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		var agency *gtfs.Agency
 		agency = createAgency(record)
 		feed.Agencies[agency.Id] = agency
+		feed.setLine("agency.txt", agency.Id, reader.Curline)
 	}
 
 	return e
@@ -180,17 +209,19 @@ func (feed *Feed) parseStops(path string) (err error) {
 
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"stops.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "stops.txt", reader.Curline, record["stop_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		var stop *gtfs.Stop
 		stop = createStop(record)
 		feed.Stops[stop.Id] = stop
+		feed.setLine("stops.txt", stop.Id, reader.Curline)
 	}
 	return e
 }
@@ -204,17 +235,19 @@ func (feed *Feed) parseRoutes(path string) (err error) {
 
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"routes.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "routes.txt", reader.Curline, record["route_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		var route *gtfs.Route
 		route = createRoute(record, feed.Agencies)
 		feed.Routes[route.Id] = route
+		feed.setLine("routes.txt", route.Id, reader.Curline)
 	}
 	return e
 }
@@ -228,16 +261,18 @@ func (feed *Feed) parseCalendar(path string) (err error) {
 
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"calendar.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "calendar.txt", reader.Curline, record["service_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		var service *gtfs.Service
 		service = createServiceFromCalendar(record, feed.Services)
+		feed.setLine("calendar.txt", record["service_id"], reader.Curline)
 
 		// if service was parsed in-place, nil was returned
 		if service != nil {
@@ -257,16 +292,18 @@ func (feed *Feed) parseCalendarDates(path string) (err error) {
 
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"calendar_dates.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "calendar_dates.txt", reader.Curline, record["service_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		var service *gtfs.Service
 		service = createServiceFromCalendarDates(record, feed.Services)
+		feed.setLine("calendar_dates.txt", record["service_id"], reader.Curline)
 
 		// if service was parsed in-place, nil was returned
 		if service != nil {
@@ -286,17 +323,19 @@ func (feed *Feed) parseTrips(path string) (err error) {
 
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"trips.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "trips.txt", reader.Curline, record["trip_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		var trip *gtfs.Trip
 		trip = createTrip(record, feed.Routes, feed.Services, feed.Shapes)
 		feed.Trips[trip.Id] = trip
+		feed.setLine("trips.txt", trip.Id, reader.Curline)
 	}
 
 	return e
@@ -311,15 +350,17 @@ func (feed *Feed) parseShapes(path string) (err error) {
 
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"shapes.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "shapes.txt", reader.Curline, record["shape_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		createShapePoint(record, feed.Shapes)
+		feed.setLine("shapes.txt", record["shape_id"], reader.Curline)
 	}
 
 	return e
@@ -333,15 +374,17 @@ func (feed *Feed) parseStopTimes(path string) (err error) {
 	}
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"stop_times.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "stop_times.txt", reader.Curline, record["trip_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		createStopTime(record, feed.Stops, feed.Trips)
+		feed.setLine("stop_times.txt", record["trip_id"], reader.Curline)
 	}
 
 	return e
@@ -355,15 +398,17 @@ func (feed *Feed) parseFrequencies(path string) (err error) {
 	}
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"frequencies.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "frequencies.txt", reader.Curline, record["trip_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		createFrequency(record, feed.Trips)
+		feed.setLine("frequencies.txt", record["trip_id"], reader.Curline)
 	}
 
 	return e
@@ -377,17 +422,19 @@ func (feed *Feed) parseFareAttributes(path string) (err error) {
 	}
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"fare_attributes.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "fare_attributes.txt", reader.Curline, record["fare_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		var fa *gtfs.FareAttribute
 		fa = createFareAttribute(record)
 		feed.FareAttributes[fa.Id] = fa
+		feed.setLine("fare_attributes.txt", fa.Id, reader.Curline)
 	}
 
 	return e
@@ -401,15 +448,17 @@ func (feed *Feed) parseFareAttributeRules(path string) (err error) {
 	}
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"fare_rules.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "fare_rules.txt", reader.Curline, record["fare_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		createFareRule(record, feed.FareAttributes, feed.Routes)
+		feed.setLine("fare_rules.txt", record["fare_id"], reader.Curline)
 	}
 
 	return e
@@ -423,15 +472,17 @@ func (feed *Feed) parseTransfers(path string) (err error) {
 	}
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"transfers.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "transfers.txt", reader.Curline, record["from_stop_id"])
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		feed.Transfers = append(feed.Transfers, createTransfer(record, feed.Stops))
+		feed.setLine("transfers.txt", record["from_stop_id"], reader.Curline)
 	}
 
 	return e
@@ -445,13 +496,14 @@ func (feed *Feed) parseFeedInfos(path string) (err error) {
 	}
 	reader := NewCsvParser(file)
 
+	var record map[string]string
+
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"feed_info.txt", reader.Curline, r.(error).Error()}
+			err = newParseError(r, "feed_info.txt", reader.Curline, "")
 		}
 	}()
 
-	var record map[string]string
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		feed.FeedInfos = append(feed.FeedInfos, createFeedInfo(record))
 	}
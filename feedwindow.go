@@ -0,0 +1,190 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+	"time"
+)
+
+const (
+	CodeServiceNeverActive    = "service_never_active"
+	CodeFeedInfoInvalidWindow = "feed_info_invalid_window"
+	CodeServiceOutsideWindow  = "service_outside_feed_window"
+	CodeFeedWindowNoService   = "feed_window_without_service"
+	CodeFeedExpired           = "feed_expired"
+	CodeFeedExpiresSoon       = "feed_expires_soon"
+)
+
+// Get the effective service window of the feed, that is the first and
+// the last date on which any service is active. Empty dates are returned
+// if no service is ever active.
+func (feed *Feed) GetServiceWindow() (gtfs.Date, gtfs.Date) {
+	var start, end gtfs.Date
+
+	for _, s := range feed.Services {
+		first, last := s.GetFirstActiveDate(), s.GetLastActiveDate()
+		if first.IsEmpty() {
+			continue
+		}
+		if start.IsEmpty() || first.Before(start) {
+			start = first
+		}
+		if end.IsEmpty() || last.After(end) {
+			end = last
+		}
+	}
+
+	return start, end
+}
+
+// Checks the feed's validity window against its services
+type ValidityValidator struct {
+	// the date the feed's expiry is checked against
+	RefDate gtfs.Date
+
+	// warn if the feed expires within this many days after RefDate
+	MinDaysLeft int
+}
+
+// Create a new ValidityValidator which checks against the current date
+func NewValidityValidator() *ValidityValidator {
+	return &ValidityValidator{
+		RefDate:     gtfs.GetDate(time.Now()),
+		MinDaysLeft: 7,
+	}
+}
+
+func (v *ValidityValidator) Validate(feed *Feed) []Finding {
+	findings := make([]Finding, 0)
+
+	ids := make([]string, 0, len(feed.Services))
+	for id := range feed.Services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if feed.Services[id].GetFirstActiveDate().IsEmpty() {
+			filename := "calendar.txt"
+			if feed.Services[id].Start_date.IsEmpty() {
+				filename = "calendar_dates.txt"
+			}
+
+			findings = append(findings, Finding{
+				Code:     CodeServiceNeverActive,
+				Severity: SeverityWarning,
+				Filename: filename,
+				EntityId: id,
+				Field:    "service_id",
+				Msg:      fmt.Sprintf("Service %s is never active", id),
+			})
+		}
+	}
+
+	start, end := feed.GetServiceWindow()
+
+	if start.IsEmpty() {
+		return findings
+	}
+
+	for _, fi := range feed.FeedInfos {
+		findings = append(findings, v.checkFeedInfo(fi, start, end)...)
+	}
+
+	// locate the expiry at the first service active on the last date
+	var lastId, filename, field string
+	for _, id := range ids {
+		if s := feed.Services[id]; s.GetLastActiveDate() == end {
+			lastId, filename, field = id, "calendar.txt", "end_date"
+			if s.Start_date.IsEmpty() || s.GetExceptionTypeOn(end) == 1 {
+				filename, field = "calendar_dates.txt", "date"
+			}
+			break
+		}
+	}
+
+	if end.Before(v.RefDate) {
+		findings = append(findings, Finding{
+			Code:     CodeFeedExpired,
+			Severity: SeverityError,
+			Filename: filename,
+			EntityId: lastId,
+			Field:    field,
+			Msg:      fmt.Sprintf("Feed expired on %s, before %s", end, v.RefDate),
+		})
+	} else if end.Before(v.RefDate.AddDays(v.MinDaysLeft)) {
+		findings = append(findings, Finding{
+			Code:     CodeFeedExpiresSoon,
+			Severity: SeverityWarning,
+			Filename: filename,
+			EntityId: lastId,
+			Field:    field,
+			Msg:      fmt.Sprintf("Feed expires on %s, less than %d days after %s", end, v.MinDaysLeft, v.RefDate),
+		})
+	}
+
+	return findings
+}
+
+func (v *ValidityValidator) checkFeedInfo(fi *gtfs.FeedInfo, start gtfs.Date, end gtfs.Date) []Finding {
+	findings := make([]Finding, 0)
+
+	if !fi.Start_date.IsEmpty() && !fi.End_date.IsEmpty() && fi.End_date.Before(fi.Start_date) {
+		return append(findings, Finding{
+			Code:     CodeFeedInfoInvalidWindow,
+			Severity: SeverityError,
+			Filename: "feed_info.txt",
+			Field:    "feed_end_date",
+			Msg:      fmt.Sprintf("Feed end date %s lies before feed start date %s", fi.End_date, fi.Start_date),
+		})
+	}
+
+	if !fi.Start_date.IsEmpty() && start.Before(fi.Start_date) {
+		findings = append(findings, Finding{
+			Code:     CodeServiceOutsideWindow,
+			Severity: SeverityWarning,
+			Filename: "feed_info.txt",
+			Field:    "feed_start_date",
+			Msg:      fmt.Sprintf("Service starts on %s, before feed start date %s", start, fi.Start_date),
+		})
+	}
+
+	if !fi.End_date.IsEmpty() && end.After(fi.End_date) {
+		findings = append(findings, Finding{
+			Code:     CodeServiceOutsideWindow,
+			Severity: SeverityWarning,
+			Filename: "feed_info.txt",
+			Field:    "feed_end_date",
+			Msg:      fmt.Sprintf("Service ends on %s, after feed end date %s", end, fi.End_date),
+		})
+	}
+
+	if !fi.Start_date.IsEmpty() && fi.Start_date.Before(start) {
+		findings = append(findings, Finding{
+			Code:     CodeFeedWindowNoService,
+			Severity: SeverityInfo,
+			Filename: "feed_info.txt",
+			Field:    "feed_start_date",
+			Msg:      fmt.Sprintf("Feed start date %s lies before the first service date %s", fi.Start_date, start),
+		})
+	}
+
+	if !fi.End_date.IsEmpty() && fi.End_date.After(end) {
+		findings = append(findings, Finding{
+			Code:     CodeFeedWindowNoService,
+			Severity: SeverityInfo,
+			Filename: "feed_info.txt",
+			Field:    "feed_end_date",
+			Msg:      fmt.Sprintf("Feed end date %s lies after the last service date %s", fi.End_date, end),
+		})
+	}
+
+	return findings
+}
@@ -0,0 +1,43 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"testing"
+)
+
+func TestValidityValidatorFilenames(t *testing.T) {
+	feed := newTestFeed()
+	last := gtfs.Date{Day: 31, Month: 12, Year: 2020}
+
+	// services only defined in calendar_dates.txt
+	feed.Services["DATES"] = &gtfs.Service{Id: "DATES", Exceptions: []*gtfs.ServiceException{{Date: last.AddDays(1), Type: 1}}}
+	feed.Services["NEVER"] = &gtfs.Service{Id: "NEVER", Exceptions: []*gtfs.ServiceException{{Date: last, Type: 2}}}
+
+	v := &ValidityValidator{RefDate: gtfs.Date{Day: 1, Month: 6, Year: 2021}, MinDaysLeft: 7}
+	findings := feed.Validate(v)
+
+	got := make(map[string]Finding)
+	for _, f := range findings {
+		got[f.Code] = f
+	}
+
+	if f := got[CodeServiceNeverActive]; f.EntityId != "NEVER" || f.Filename != "calendar_dates.txt" {
+		t.Errorf("got never active service %s in %s, want NEVER in calendar_dates.txt", f.EntityId, f.Filename)
+	}
+	if f := got[CodeFeedExpired]; f.EntityId != "DATES" || f.Filename != "calendar_dates.txt" || f.Field != "date" {
+		t.Errorf("got expiry at %s in %s, want DATES in calendar_dates.txt", f.EntityId, f.Filename)
+	}
+
+	delete(feed.Services, "DATES")
+	for _, f := range feed.Validate(v) {
+		if f.Code == CodeFeedExpired && (f.Filename != "calendar.txt" || f.Field != "end_date") {
+			t.Errorf("got expiry in %s, want calendar.txt", f.Filename)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"net/url"
+	"sort"
+)
+
+const (
+	CodeInvalidColor = "invalid_color"
+	CodeInvalidUrl   = "invalid_url"
+	CodeLowContrast  = "low_contrast"
+)
+
+// Checks the format of color and URL fields
+type FieldValidator struct {
+	// minimum WCAG contrast ratio between route color and route text color
+	MinContrast float64
+}
+
+// Create a new FieldValidator with default thresholds
+func NewFieldValidator() *FieldValidator {
+	return &FieldValidator{
+		MinContrast: 3,
+	}
+}
+
+func (v *FieldValidator) Validate(feed *Feed) []Finding {
+	findings := make([]Finding, 0)
+
+	agencyIds := make([]string, 0, len(feed.Agencies))
+	for id := range feed.Agencies {
+		agencyIds = append(agencyIds, id)
+	}
+	sort.Strings(agencyIds)
+
+	for _, id := range agencyIds {
+		a := feed.Agencies[id]
+		findings = appendUrlFinding(findings, a.Url, "agency.txt", a.Id, "agency_url")
+		findings = appendUrlFinding(findings, a.Fare_url, "agency.txt", a.Id, "agency_fare_url")
+	}
+
+	routeIds := make([]string, 0, len(feed.Routes))
+	for id := range feed.Routes {
+		routeIds = append(routeIds, id)
+	}
+	sort.Strings(routeIds)
+
+	for _, id := range routeIds {
+		r := feed.Routes[id]
+		findings = appendUrlFinding(findings, r.Url, "routes.txt", r.Id, "route_url")
+		findings = append(findings, v.checkRouteColors(r)...)
+	}
+
+	stopIds := make([]string, 0, len(feed.Stops))
+	for id := range feed.Stops {
+		stopIds = append(stopIds, id)
+	}
+	sort.Strings(stopIds)
+
+	for _, id := range stopIds {
+		s := feed.Stops[id]
+		findings = appendUrlFinding(findings, s.Url, "stops.txt", s.Id, "stop_url")
+	}
+
+	for _, fi := range feed.FeedInfos {
+		findings = appendUrlFinding(findings, fi.Publisher_url, "feed_info.txt", "", "feed_publisher_url")
+	}
+
+	return findings
+}
+
+func (v *FieldValidator) checkRouteColors(r *gtfs.Route) []Finding {
+	findings := make([]Finding, 0)
+	valid := true
+
+	for _, c := range []struct{ val, field string }{{r.Color, "route_color"}, {r.Text_color, "route_text_color"}} {
+		if len(c.val) == 0 {
+			continue
+		}
+		if _, err := gtfs.ParseColor(c.val); err != nil {
+			valid = false
+			findings = append(findings, Finding{
+				Code:     CodeInvalidColor,
+				Severity: SeverityError,
+				Filename: "routes.txt",
+				EntityId: r.Id,
+				Field:    c.field,
+				Msg:      err.Error(),
+			})
+		}
+	}
+
+	// only check the contrast if at least one color was explicitly set
+	if !valid || (len(r.Color) == 0 && len(r.Text_color) == 0) {
+		return findings
+	}
+
+	if ratio := gtfs.ContrastRatio(r.RGB(), r.TextRGB()); ratio < v.MinContrast {
+		findings = append(findings, Finding{
+			Code:     CodeLowContrast,
+			Severity: SeverityWarning,
+			Filename: "routes.txt",
+			EntityId: r.Id,
+			Field:    "route_text_color",
+			Msg: fmt.Sprintf("Contrast ratio %.2f between route color %s and text color %s is below %.2f",
+				ratio, r.RGB(), r.TextRGB(), v.MinContrast),
+		})
+	}
+
+	return findings
+}
+
+func appendUrlFinding(findings []Finding, val string, filename string, id string, field string) []Finding {
+	if len(val) == 0 || isValidUrl(val) {
+		return findings
+	}
+
+	return append(findings, Finding{
+		Code:     CodeInvalidUrl,
+		Severity: SeverityError,
+		Filename: filename,
+		EntityId: id,
+		Field:    field,
+		Msg:      fmt.Sprintf("Expected absolute http(s) URL for field '%s', found '%s'", field, val),
+	})
+}
+
+// Check whether s is an absolute http or https URL
+func isValidUrl(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
@@ -0,0 +1,258 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+)
+
+// A Filter removes entities from a feed, leaving a self-consistent feed
+type Filter interface {
+	Apply(feed *Feed) *Removed
+}
+
+// The IDs of the entities removed from a feed
+type Removed struct {
+	Agencies       []string
+	Stops          []string
+	Routes         []string
+	Trips          []string
+	Services       []string
+	Shapes         []string
+	FareAttributes []string
+	Transfers      int
+	FareRules      int
+}
+
+// Apply the given filters to the feed, in order
+func (feed *Feed) Filter(filters ...Filter) *Removed {
+	removed := &Removed{}
+
+	for _, f := range filters {
+		removed.add(f.Apply(feed))
+	}
+
+	return removed
+}
+
+func (r *Removed) add(o *Removed) {
+	r.Agencies = append(r.Agencies, o.Agencies...)
+	r.Stops = append(r.Stops, o.Stops...)
+	r.Routes = append(r.Routes, o.Routes...)
+	r.Trips = append(r.Trips, o.Trips...)
+	r.Services = append(r.Services, o.Services...)
+	r.Shapes = append(r.Shapes, o.Shapes...)
+	r.FareAttributes = append(r.FareAttributes, o.FareAttributes...)
+	r.Transfers += o.Transfers
+	r.FareRules += o.FareRules
+}
+
+func (r *Removed) sort() {
+	for _, ids := range [][]string{r.Agencies, r.Stops, r.Routes, r.Trips, r.Services, r.Shapes, r.FareAttributes} {
+		sort.Strings(ids)
+	}
+}
+
+// The entities of a feed which are in use
+type usedEntities struct {
+	stops    map[*gtfs.Stop]bool
+	routes   map[*gtfs.Route]bool
+	services map[*gtfs.Service]bool
+	shapes   map[*gtfs.Shape]bool
+	agencies map[*gtfs.Agency]bool
+	zones    map[string]bool
+}
+
+// Get the entities in use: stops served by any trip together with their
+// parent stations, the routes, services and shapes of trips, the
+// agencies of routes and the zones of stops
+func (feed *Feed) getUsed() *usedEntities {
+	used := &usedEntities{
+		stops:    make(map[*gtfs.Stop]bool),
+		routes:   make(map[*gtfs.Route]bool),
+		services: make(map[*gtfs.Service]bool),
+		shapes:   make(map[*gtfs.Shape]bool),
+		agencies: make(map[*gtfs.Agency]bool),
+		zones:    make(map[string]bool),
+	}
+
+	for _, trip := range feed.Trips {
+		used.routes[trip.Route] = true
+		used.services[trip.Service] = true
+		if trip.Shape != nil {
+			used.shapes[trip.Shape] = true
+		}
+		for _, st := range trip.StopTimes {
+			used.stops[st.Stop] = true
+		}
+	}
+
+	for stop := range used.stops {
+		if parent, ok := feed.Stops[stop.Parent_station]; ok {
+			used.stops[parent] = true
+		}
+	}
+
+	for _, route := range feed.Routes {
+		used.agencies[feed.getRouteAgency(route)] = true
+	}
+
+	for _, stop := range feed.Stops {
+		used.zones[stop.Zone_id] = true
+	}
+
+	return used
+}
+
+// Get all entities of the feed, as if all of them were in use. Zones
+// referred to by fare rules are included.
+func (feed *Feed) getAll() *usedEntities {
+	all := &usedEntities{
+		stops:    make(map[*gtfs.Stop]bool),
+		routes:   make(map[*gtfs.Route]bool),
+		services: make(map[*gtfs.Service]bool),
+		shapes:   make(map[*gtfs.Shape]bool),
+		agencies: make(map[*gtfs.Agency]bool),
+		zones:    make(map[string]bool),
+	}
+
+	for _, stop := range feed.Stops {
+		all.stops[stop] = true
+		all.zones[stop.Zone_id] = true
+	}
+	for _, route := range feed.Routes {
+		all.routes[route] = true
+	}
+	for _, service := range feed.Services {
+		all.services[service] = true
+	}
+	for _, shape := range feed.Shapes {
+		all.shapes[shape] = true
+	}
+	for _, agency := range feed.Agencies {
+		all.agencies[agency] = true
+	}
+	for _, fa := range feed.FareAttributes {
+		for _, r := range fa.Rules {
+			all.zones[r.Origin_id] = true
+			all.zones[r.Destination_id] = true
+			all.zones[r.Contains_id] = true
+		}
+	}
+
+	return all
+}
+
+func (feed *Feed) removeTrip(trip *gtfs.Trip, removed *Removed) {
+	delete(feed.Trips, trip.Id)
+	removed.Trips = append(removed.Trips, trip.Id)
+}
+
+func (feed *Feed) removeStop(stop *gtfs.Stop, removed *Removed) {
+	delete(feed.Stops, stop.Id)
+	removed.Stops = append(removed.Stops, stop.Id)
+}
+
+// Remove all entities which were used before trips were removed or
+// truncated and are no longer used now: routes, services and shapes
+// without trips, agencies without routes and stops no longer served.
+// Transfers and fare rules referring to removed entities are removed
+// as well. Entities which were already unused are kept.
+func (feed *Feed) removeOrphans(before *usedEntities, removed *Removed) {
+	now := feed.getUsed()
+
+	for id, route := range feed.Routes {
+		if before.routes[route] && !now.routes[route] {
+			delete(feed.Routes, id)
+			removed.Routes = append(removed.Routes, id)
+		}
+	}
+
+	for id, service := range feed.Services {
+		if before.services[service] && !now.services[service] {
+			delete(feed.Services, id)
+			removed.Services = append(removed.Services, id)
+		}
+	}
+
+	for id, shape := range feed.Shapes {
+		if before.shapes[shape] && !now.shapes[shape] {
+			delete(feed.Shapes, id)
+			removed.Shapes = append(removed.Shapes, id)
+		}
+	}
+
+	agencies := make(map[*gtfs.Agency]bool)
+	for _, route := range feed.Routes {
+		agencies[feed.getRouteAgency(route)] = true
+	}
+
+	for id, agency := range feed.Agencies {
+		if before.agencies[agency] && !agencies[agency] {
+			delete(feed.Agencies, id)
+			removed.Agencies = append(removed.Agencies, id)
+		}
+	}
+
+	for _, stop := range feed.Stops {
+		if before.stops[stop] && !now.stops[stop] {
+			feed.removeStop(stop, removed)
+		}
+	}
+
+	transfers := make([]*gtfs.Transfer, 0, len(feed.Transfers))
+	for _, t := range feed.Transfers {
+		if feed.Stops[t.From_stop.Id] == t.From_stop && feed.Stops[t.To_stop.Id] == t.To_stop {
+			transfers = append(transfers, t)
+		} else {
+			removed.Transfers++
+		}
+	}
+	feed.Transfers = transfers
+
+	feed.removeOrphanFareRules(before.zones, removed)
+
+	removed.sort()
+
+	feed.InvalidateIndex()
+}
+
+// Remove fare rules referring to removed routes or to zones of
+// zonesBefore which lost all their stops, and fare attributes which
+// lost all their rules
+func (feed *Feed) removeOrphanFareRules(zonesBefore map[string]bool, removed *Removed) {
+	zones := make(map[string]bool)
+	for _, stop := range feed.Stops {
+		zones[stop.Zone_id] = true
+	}
+	removedZone := func(id string) bool {
+		return len(id) > 0 && zonesBefore[id] && !zones[id]
+	}
+
+	for id, fa := range feed.FareAttributes {
+		if len(fa.Rules) == 0 {
+			continue
+		}
+
+		rules := make([]*gtfs.FareAttributeRule, 0, len(fa.Rules))
+		for _, r := range fa.Rules {
+			if (r.Route != nil && feed.Routes[r.Route.Id] != r.Route) ||
+				removedZone(r.Origin_id) || removedZone(r.Destination_id) || removedZone(r.Contains_id) {
+				removed.FareRules++
+				continue
+			}
+			rules = append(rules, r)
+		}
+		fa.Rules = rules
+
+		if len(rules) == 0 {
+			delete(feed.FareAttributes, id)
+			removed.FareAttributes = append(removed.FareAttributes, id)
+		}
+	}
+}
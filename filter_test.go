@@ -0,0 +1,135 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+	"testing"
+)
+
+// Build a small feed along a line of stops S1 to S4, about 7.6 km
+// apart. Every entity ending in X is unused.
+//
+//	T1 (R1, A1, weekdays, SH1): S1 08:00, S2 08:10, S3 08:20, every 10 minutes until 09:00
+//	T2 (R2, A2, saturdays):     S3 09:00, S4 09:10
+func newTestFeed() *Feed {
+	feed := NewFeed()
+
+	for _, id := range []string{"A1", "A2", "AX"} {
+		feed.Agencies[id] = &gtfs.Agency{Id: id, Name: id, Timezone: "Europe/Berlin"}
+	}
+
+	for _, s := range []*gtfs.Stop{
+		{Id: "S1", Lat: 47, Lon: 7.0, Zone_id: "z1"},
+		{Id: "S2", Lat: 47, Lon: 7.1, Zone_id: "z2"},
+		{Id: "S3", Lat: 47, Lon: 7.2, Zone_id: "z3"},
+		{Id: "S4", Lat: 47, Lon: 7.3, Zone_id: "z4"},
+		{Id: "SX", Lat: 47, Lon: 7.12},
+	} {
+		feed.Stops[s.Id] = s
+	}
+
+	start, end := gtfs.Date{Day: 1, Month: 1, Year: 2020}, gtfs.Date{Day: 31, Month: 12, Year: 2020}
+	feed.Services["WEEK"] = &gtfs.Service{Id: "WEEK", Daymap: [7]bool{false, true, true, true, true, true, false}, Start_date: start, End_date: end}
+	feed.Services["SAT"] = &gtfs.Service{Id: "SAT", Daymap: [7]bool{false, false, false, false, false, false, true}, Start_date: start, End_date: end}
+	feed.Services["UNUSED"] = &gtfs.Service{Id: "UNUSED", Daymap: [7]bool{true, true, true, true, true, true, true}, Start_date: start, End_date: end}
+
+	feed.Routes["R1"] = &gtfs.Route{Id: "R1", Agency: feed.Agencies["A1"], Type: 3}
+	feed.Routes["R2"] = &gtfs.Route{Id: "R2", Agency: feed.Agencies["A2"], Type: 0}
+	feed.Routes["RX"] = &gtfs.Route{Id: "RX", Agency: feed.Agencies["A1"], Type: 3}
+
+	feed.Shapes["SH1"] = &gtfs.Shape{Id: "SH1", Points: gtfs.ShapePoints{
+		{Lat: 47, Lon: 6.95, Sequence: 0},
+		{Lat: 47, Lon: 7.0, Sequence: 1},
+		{Lat: 47, Lon: 7.1, Sequence: 2},
+		{Lat: 47, Lon: 7.2, Sequence: 3},
+		{Lat: 47, Lon: 7.25, Sequence: 4},
+	}}
+	feed.Shapes["SHX"] = &gtfs.Shape{Id: "SHX", Points: gtfs.ShapePoints{
+		{Lat: 46, Lon: 7, Sequence: 0},
+		{Lat: 46, Lon: 8, Sequence: 1},
+	}}
+
+	addTrip(feed, "T1", "R1", "WEEK", "S1", "08:00:00", "S2", "08:10:00", "S3", "08:20:00")
+	feed.Trips["T1"].Shape = feed.Shapes["SH1"]
+	feed.Trips["T1"].Frequencies = []*gtfs.Frequency{{Start_time: "08:00:00", End_time: "09:00:00", Headway_secs: 600}}
+
+	addTrip(feed, "T2", "R2", "SAT", "S3", "09:00:00", "S4", "09:10:00")
+
+	feed.Transfers = append(feed.Transfers, &gtfs.Transfer{From_stop: feed.Stops["S3"], To_stop: feed.Stops["S3"], Transfer_type: 2, Min_transfer_time: 120})
+
+	feed.FareAttributes["F1"] = &gtfs.FareAttribute{Id: "F1", Price: "2.00", Currency_type: "EUR", Rules: []*gtfs.FareAttributeRule{{Route: feed.Routes["R1"]}}}
+	feed.FareAttributes["F2"] = &gtfs.FareAttribute{Id: "F2", Price: "3.00", Currency_type: "EUR", Rules: []*gtfs.FareAttributeRule{{Origin_id: "z4"}}}
+	feed.FareAttributes["FX"] = &gtfs.FareAttribute{Id: "FX", Price: "4.00", Currency_type: "EUR", Rules: []*gtfs.FareAttributeRule{{Origin_id: "zx"}}}
+
+	return feed
+}
+
+// Add a trip with the given stops and times, given as pairs
+func addTrip(feed *Feed, id string, route string, service string, stops ...string) {
+	trip := &gtfs.Trip{Id: id, Route: feed.Routes[route], Service: feed.Services[service]}
+	for i := 0; i < len(stops); i += 2 {
+		trip.StopTimes = append(trip.StopTimes, &gtfs.StopTime{
+			Stop:           feed.Stops[stops[i]],
+			Sequence:       i / 2,
+			Arrival_time:   stops[i+1],
+			Departure_time: stops[i+1],
+		})
+	}
+	feed.Trips[id] = trip
+}
+
+func getIds(m interface{}) []string {
+	ret := make([]string, 0)
+	switch m := m.(type) {
+	case map[string]*gtfs.Agency:
+		for id := range m {
+			ret = append(ret, id)
+		}
+	case map[string]*gtfs.Stop:
+		for id := range m {
+			ret = append(ret, id)
+		}
+	case map[string]*gtfs.Route:
+		for id := range m {
+			ret = append(ret, id)
+		}
+	case map[string]*gtfs.Trip:
+		for id := range m {
+			ret = append(ret, id)
+		}
+	case map[string]*gtfs.Service:
+		for id := range m {
+			ret = append(ret, id)
+		}
+	case map[string]*gtfs.Shape:
+		for id := range m {
+			ret = append(ret, id)
+		}
+	case map[string]*gtfs.FareAttribute:
+		for id := range m {
+			ret = append(ret, id)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func checkIds(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("got %s %v, want %v", what, got, want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %s %v, want %v", what, got, want)
+			return
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+	"strings"
+)
+
+// Replace all frequency-based trips by their concrete trip instances.
+// If exactOnly is set, only trips whose frequencies all have exact
+// times are expanded. Returns the number of created trips.
+func (feed *Feed) ExpandFrequencies(exactOnly bool) int {
+	count := 0
+
+	ids := make([]string, 0)
+	for id, trip := range feed.Trips {
+		if len(trip.Frequencies) > 0 && (!exactOnly || hasExactTimes(trip)) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		insts := feed.Trips[id].ExpandFrequencies()
+		if len(insts) == 0 {
+			continue
+		}
+
+		delete(feed.Trips, id)
+		for _, inst := range insts {
+			inst.Id = feed.getFreeTripId(inst.Id)
+			feed.Trips[inst.Id] = inst
+		}
+		count += len(insts)
+	}
+
+	feed.InvalidateIndex()
+
+	return count
+}
+
+// Get id, or the first free id_<n> if a trip with this ID exists
+func (feed *Feed) getFreeTripId(id string) string {
+	ret := id
+	for n := 2; feed.Trips[ret] != nil; n++ {
+		ret = fmt.Sprintf("%s_%d", id, n)
+	}
+	return ret
+}
+
+func hasExactTimes(trip *gtfs.Trip) bool {
+	for _, f := range trip.Frequencies {
+		if !f.Exact_times {
+			return false
+		}
+	}
+	return true
+}
+
+// Replace sets of at least minTrips trips which only differ in their
+// start time and run at a regular headway by a single trip with an
+// exact-times frequency. Returns the number of removed trips.
+func (feed *Feed) CompactFrequencies(minTrips int) int {
+	if minTrips < 2 {
+		minTrips = 2
+	}
+
+	type candidate struct {
+		trip  *gtfs.Trip
+		start int
+	}
+
+	// group trips which are identical except for their start time
+	groups := make(map[string][]candidate)
+
+	for _, trip := range feed.Trips {
+		if len(trip.Frequencies) > 0 || len(trip.StopTimes) == 0 {
+			continue
+		}
+
+		start, err := getFirstDeparture(trip)
+		if err != nil {
+			continue
+		}
+
+		sig, ok := getTripSignature(trip, start)
+		if !ok {
+			continue
+		}
+
+		groups[sig] = append(groups[sig], candidate{trip, start})
+	}
+
+	count := 0
+
+	for _, group := range groups {
+		if len(group) < minTrips {
+			continue
+		}
+
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].start != group[j].start {
+				return group[i].start < group[j].start
+			}
+			return group[i].trip.Id < group[j].trip.Id
+		})
+
+		// find maximal runs with a constant headway
+		for i := 0; i+1 < len(group); {
+			headway := group[i+1].start - group[i].start
+			j := i + 1
+			for j+1 < len(group) && group[j+1].start-group[j].start == headway {
+				j++
+			}
+
+			if headway <= 0 || j-i+1 < minTrips {
+				i++
+				continue
+			}
+
+			tmpl := group[i].trip
+			tmpl.Frequencies = []*gtfs.Frequency{{
+				Start_time:   gtfs.FormatTime(group[i].start),
+				End_time:     gtfs.FormatTime(group[j].start + headway),
+				Headway_secs: headway,
+				Exact_times:  true,
+			}}
+
+			for k := i + 1; k <= j; k++ {
+				delete(feed.Trips, group[k].trip.Id)
+				count++
+			}
+
+			i = j + 1
+		}
+	}
+
+	feed.InvalidateIndex()
+
+	return count
+}
+
+// Get a string identifying all attributes of a trip except its ID and
+// its start time. Returns false if the trip has untimed stops.
+func getTripSignature(trip *gtfs.Trip, start int) (string, bool) {
+	var b strings.Builder
+
+	shapeId := ""
+	if trip.Shape != nil {
+		shapeId = trip.Shape.Id
+	}
+
+	fmt.Fprintf(&b, "%p|%p|%s|%q|%q|%d|%q|%d|%d", trip.Route, trip.Service, shapeId, trip.Headsign,
+		trip.Short_name, trip.Direction_id, trip.Block_id, trip.Wheelchair_accessible, trip.Bikes_allowed)
+
+	for _, st := range trip.StopTimes {
+		arr, err := gtfs.ParseTime(st.Arrival_time)
+		if err != nil {
+			return "", false
+		}
+		dep, err := gtfs.ParseTime(st.Departure_time)
+		if err != nil {
+			return "", false
+		}
+
+		fmt.Fprintf(&b, "|%q,%d,%d,%d,%q,%d,%d,%g,%t", st.Stop.Id, st.Sequence, arr-start, dep-start,
+			st.Headsign, st.Pickup_type, st.Drop_off_type, st.Shape_dist_traveled, st.Timepoint)
+	}
+
+	return b.String(), true
+}
@@ -0,0 +1,76 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"testing"
+)
+
+func TestExpandCompactFrequencies(t *testing.T) {
+	feed := newTestFeed()
+	feed.Trips["T1"].Frequencies[0].Exact_times = true
+
+	if n := feed.ExpandFrequencies(false); n != 6 {
+		t.Fatalf("got %d created trips, want 6", n)
+	}
+
+	checkIds(t, "trips", getIds(feed.Trips), "T1_08:00:00", "T1_08:10:00", "T1_08:20:00", "T1_08:30:00", "T1_08:40:00", "T1_08:50:00", "T2")
+
+	inst := feed.Trips["T1_08:20:00"]
+	if len(inst.Frequencies) != 0 {
+		t.Errorf("got %d frequencies for an instance, want 0", len(inst.Frequencies))
+	}
+	if dep := inst.StopTimes[2].Departure_time; dep != "08:40:00" {
+		t.Errorf("got departure %s at the last stop, want 08:40:00", dep)
+	}
+
+	if n := feed.CompactFrequencies(2); n != 5 {
+		t.Fatalf("got %d removed trips, want 5", n)
+	}
+
+	checkIds(t, "trips", getIds(feed.Trips), "T1_08:00:00", "T2")
+
+	trip := feed.Trips["T1_08:00:00"]
+	if len(trip.Frequencies) != 1 {
+		t.Fatalf("got %d frequencies, want 1", len(trip.Frequencies))
+	}
+	if f := trip.Frequencies[0]; f.Start_time != "08:00:00" || f.End_time != "09:00:00" || f.Headway_secs != 600 || !f.Exact_times {
+		t.Errorf("got frequency %v, want every 600 s from 08:00:00 to 09:00:00 with exact times", *f)
+	}
+	if dep := trip.StopTimes[2].Departure_time; dep != "08:20:00" {
+		t.Errorf("got departure %s at the last stop, want 08:20:00", dep)
+	}
+}
+
+func TestExpandFrequenciesEdgeCases(t *testing.T) {
+	feed := newTestFeed()
+
+	// frequencies without any instance leave the trip unchanged
+	feed.Trips["T1"].Frequencies[0].End_time = "07:00:00"
+	if n := feed.ExpandFrequencies(false); n != 0 {
+		t.Errorf("got %d created trips, want 0", n)
+	}
+	checkIds(t, "trips", getIds(feed.Trips), "T1", "T2")
+
+	// only exact frequencies are expanded
+	feed = newTestFeed()
+	if n := feed.ExpandFrequencies(true); n != 0 {
+		t.Errorf("got %d created trips, want 0", n)
+	}
+
+	// instance IDs do not replace existing trips
+	feed = newTestFeed()
+	addTrip(feed, "T1_08:00:00", "R2", "SAT", "S3", "10:00:00", "S4", "10:10:00")
+	feed.ExpandFrequencies(false)
+
+	if feed.Trips["T1_08:00:00"].Route.Id != "R2" {
+		t.Error("got existing trip T1_08:00:00 replaced by an instance")
+	}
+	if trip, ok := feed.Trips["T1_08:00:00_2"]; !ok || trip.Route.Id != "R1" {
+		t.Error("got no instance T1_08:00:00_2")
+	}
+}
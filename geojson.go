@@ -0,0 +1,153 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/geojson"
+	"github.com/geops/gtfsparser/gtfs"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Write all stops as a GeoJSON FeatureCollection of points, with all
+// stop attributes as properties
+func (feed *Feed) WriteStopsGeoJson(w io.Writer) error {
+	gw := geojson.NewWriter(w)
+
+	ids := make([]string, 0, len(feed.Stops))
+	for id := range feed.Stops {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		s := feed.Stops[id]
+		gw.Add("Point", geojson.Position(s.Lat, s.Lon), map[string]interface{}{
+			"stop_id":             s.Id,
+			"stop_code":           s.Code,
+			"stop_name":           s.Name,
+			"stop_desc":           s.Desc,
+			"zone_id":             s.Zone_id,
+			"stop_url":            s.Url,
+			"location_type":       s.Location_type,
+			"parent_station":      s.Parent_station,
+			"stop_timezone":       s.Timezone,
+			"wheelchair_boarding": s.Wheelchair_boarding,
+		})
+	}
+
+	return gw.Close()
+}
+
+// Write all shapes as a GeoJSON FeatureCollection of LineStrings.
+// Shapes with a single point are written as points.
+func (feed *Feed) WriteShapesGeoJson(w io.Writer) error {
+	gw := geojson.NewWriter(w)
+
+	ids := make([]string, 0, len(feed.Shapes))
+	for id := range feed.Shapes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		coords := getShapeCoords(feed.Shapes[id])
+		props := map[string]interface{}{"shape_id": id}
+
+		switch {
+		case len(coords) == 1:
+			gw.Add("Point", coords[0], props)
+		case len(coords) > 1:
+			gw.Add("LineString", coords, props)
+		}
+	}
+
+	return gw.Close()
+}
+
+// Write all routes as a GeoJSON FeatureCollection of MultiLineStrings,
+// one line for each distinct shape of the route's trips. Trips without
+// a shape are drawn as straight lines between their stops. Lines with
+// less than two positions are skipped, as are routes without any line.
+func (feed *Feed) WriteRoutesGeoJson(w io.Writer) error {
+	gw := geojson.NewWriter(w)
+
+	ids := make([]string, 0, len(feed.Routes))
+	for id := range feed.Routes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		r := feed.Routes[id]
+
+		lines := make([][][2]float64, 0)
+		seen := make(map[string]bool)
+
+		for _, trip := range feed.TripsForRoute(r) {
+			var key string
+			if trip.Shape != nil {
+				key = "shape:" + trip.Shape.Id
+			} else {
+				stopIds := make([]string, len(trip.StopTimes))
+				for i, st := range trip.StopTimes {
+					stopIds[i] = st.Stop.Id
+				}
+				key = "stops:" + strings.Join(stopIds, "\x00")
+			}
+
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			var coords [][2]float64
+			if trip.Shape != nil {
+				coords = getShapeCoords(trip.Shape)
+			} else {
+				coords = make([][2]float64, len(trip.StopTimes))
+				for i, st := range trip.StopTimes {
+					coords[i] = geojson.Position(st.Stop.Lat, st.Stop.Lon)
+				}
+			}
+
+			if len(coords) > 1 {
+				lines = append(lines, coords)
+			}
+		}
+
+		if len(lines) == 0 {
+			continue
+		}
+
+		agencyId := ""
+		if agency := feed.getRouteAgency(r); agency != nil {
+			agencyId = agency.Id
+		}
+
+		gw.Add("MultiLineString", lines, map[string]interface{}{
+			"route_id":         r.Id,
+			"agency_id":        agencyId,
+			"route_short_name": r.Short_name,
+			"route_long_name":  r.Long_name,
+			"route_type":       r.Type,
+			"route_color":      "#" + r.RGB().String(),
+			"route_text_color": "#" + r.TextRGB().String(),
+		})
+	}
+
+	return gw.Close()
+}
+
+func getShapeCoords(shape *gtfs.Shape) [][2]float64 {
+	coords := make([][2]float64, len(shape.Points))
+	for i, p := range shape.Points {
+		coords[i] = geojson.Position(p.Lat, p.Lon)
+	}
+	return coords
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+// Streaming GeoJSON output shared by the feed and routing exports
+package geojson
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+)
+
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Round a coordinate to 6 decimal places, which is about the precision
+// of the float32 it is stored in
+func RoundCoord(c float32) float64 {
+	return math.Round(float64(c)*1e6) / 1e6
+}
+
+// Get the GeoJSON position of a coordinate
+func Position(lat float32, lon float32) [2]float64 {
+	return [2]float64{RoundCoord(lon), RoundCoord(lat)}
+}
+
+// Writes a GeoJSON FeatureCollection feature by feature, so it never
+// has to be kept in memory as a whole
+type Writer struct {
+	w   io.Writer
+	n   int
+	err error
+}
+
+// Create a new Writer and write the start of the collection
+func NewWriter(w io.Writer) *Writer {
+	gw := &Writer{w: w}
+	gw.write([]byte(`{"type":"FeatureCollection","features":[`))
+	return gw
+}
+
+func (gw *Writer) write(b []byte) {
+	if gw.err == nil {
+		_, gw.err = gw.w.Write(b)
+	}
+}
+
+// Write a feature with the given geometry and properties
+func (gw *Writer) Add(geomType string, coords interface{}, props map[string]interface{}) {
+	b, err := json.Marshal(Feature{
+		Type:       "Feature",
+		Geometry:   Geometry{Type: geomType, Coordinates: coords},
+		Properties: props,
+	})
+	if err != nil {
+		if gw.err == nil {
+			gw.err = err
+		}
+		return
+	}
+
+	if gw.n > 0 {
+		gw.write([]byte(",\n"))
+	} else {
+		gw.write([]byte("\n"))
+	}
+	gw.write(b)
+	gw.n++
+}
+
+// Write the end of the collection. Returns the first error which
+// occurred while writing.
+func (gw *Writer) Close() error {
+	gw.write([]byte("\n]}\n"))
+	return gw.err
+}
@@ -0,0 +1,271 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"math"
+	"sort"
+)
+
+const (
+	CodeCoordOutOfRange   = "coordinate_out_of_range"
+	CodeCoordZero         = "coordinate_zero"
+	CodeStopOutlier       = "stop_outlier"
+	CodeStopFarFromShape  = "stop_far_from_shape"
+	CodeStopFarFromParent = "stop_far_from_parent"
+)
+
+// Checks the coordinates of stops and shapes
+type GeoValidator struct {
+	// stops farther than OutlierFactor times the median distance of all
+	// stops to the feed's center are reported, but only if they are
+	// farther than MinOutlierDist meters away from it
+	OutlierFactor  float64
+	MinOutlierDist float64
+
+	// maximum distance in meters between a stop and its trip's shape
+	MaxStopShapeDist float64
+
+	// maximum distance in meters between a stop and its parent station
+	MaxParentDist float64
+}
+
+// Create a new GeoValidator with default thresholds
+func NewGeoValidator() *GeoValidator {
+	return &GeoValidator{
+		OutlierFactor:    10,
+		MinOutlierDist:   100000,
+		MaxStopShapeDist: 150,
+		MaxParentDist:    1000,
+	}
+}
+
+func (v *GeoValidator) Validate(feed *Feed) []Finding {
+	findings := make([]Finding, 0)
+
+	stopIds := make([]string, 0, len(feed.Stops))
+	for id := range feed.Stops {
+		stopIds = append(stopIds, id)
+	}
+	sort.Strings(stopIds)
+
+	shapeIds := make([]string, 0, len(feed.Shapes))
+	for id := range feed.Shapes {
+		shapeIds = append(shapeIds, id)
+	}
+	sort.Strings(shapeIds)
+
+	// stops with invalid coordinates are excluded from all distance checks
+	valid := make(map[*gtfs.Stop]bool)
+
+	for _, id := range stopIds {
+		stop := feed.Stops[id]
+		if f := v.checkCoord(float64(stop.Lat), float64(stop.Lon), "stops.txt", stop.Id, "stop_lat"); f != nil {
+			findings = append(findings, *f)
+		} else {
+			valid[stop] = true
+		}
+	}
+
+	for _, id := range shapeIds {
+		shape := feed.Shapes[id]
+		for _, p := range shape.Points {
+			if f := v.checkCoord(float64(p.Lat), float64(p.Lon), "shapes.txt", shape.Id, "shape_pt_lat"); f != nil {
+				f.Msg = fmt.Sprintf("%s (shape point #%d)", f.Msg, p.Sequence)
+				findings = append(findings, *f)
+			}
+		}
+	}
+
+	findings = append(findings, v.checkOutliers(feed, stopIds, valid)...)
+	findings = append(findings, v.checkParents(feed, stopIds, valid)...)
+	findings = append(findings, v.checkShapeDists(feed, valid)...)
+
+	return findings
+}
+
+func (v *GeoValidator) checkCoord(lat float64, lon float64, filename string, id string, field string) *Finding {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 || math.IsNaN(lat) || math.IsNaN(lon) {
+		return &Finding{
+			Code:     CodeCoordOutOfRange,
+			Severity: SeverityError,
+			Filename: filename,
+			EntityId: id,
+			Field:    field,
+			Msg:      fmt.Sprintf("Coordinate (%f,%f) of %s is out of range", lat, lon, id),
+		}
+	}
+
+	if lat == 0 && lon == 0 {
+		return &Finding{
+			Code:     CodeCoordZero,
+			Severity: SeverityError,
+			Filename: filename,
+			EntityId: id,
+			Field:    field,
+			Msg:      fmt.Sprintf("Coordinate of %s is (0,0)", id),
+		}
+	}
+
+	return nil
+}
+
+func (v *GeoValidator) checkOutliers(feed *Feed, stopIds []string, valid map[*gtfs.Stop]bool) []Finding {
+	findings := make([]Finding, 0)
+
+	lats := make([]float64, 0, len(valid))
+	lons := make([]float64, 0, len(valid))
+	for stop := range valid {
+		lats = append(lats, float64(stop.Lat))
+		lons = append(lons, float64(stop.Lon))
+	}
+
+	if len(lats) == 0 {
+		return findings
+	}
+
+	// the median is robust against the outliers we are looking for
+	cLat, cLon := median(lats), median(lons)
+
+	dists := make(map[*gtfs.Stop]float64)
+	distList := make([]float64, 0, len(valid))
+	for stop := range valid {
+		d := gtfs.HaversineDist(cLat, cLon, float64(stop.Lat), float64(stop.Lon))
+		dists[stop] = d
+		distList = append(distList, d)
+	}
+
+	maxDist := math.Max(v.MinOutlierDist, v.OutlierFactor*median(distList))
+
+	for _, id := range stopIds {
+		stop := feed.Stops[id]
+		if !valid[stop] || dists[stop] <= maxDist {
+			continue
+		}
+		findings = append(findings, Finding{
+			Code:     CodeStopOutlier,
+			Severity: SeverityWarning,
+			Filename: "stops.txt",
+			EntityId: stop.Id,
+			Field:    "stop_lat",
+			Msg: fmt.Sprintf("Stop %s is %.0f km away from the feed's center (%f,%f)",
+				stop.Id, dists[stop]/1000, cLat, cLon),
+		})
+	}
+
+	return findings
+}
+
+func (v *GeoValidator) checkParents(feed *Feed, stopIds []string, valid map[*gtfs.Stop]bool) []Finding {
+	findings := make([]Finding, 0)
+
+	for _, id := range stopIds {
+		stop := feed.Stops[id]
+		if len(stop.Parent_station) == 0 {
+			continue
+		}
+
+		parent, ok := feed.Stops[stop.Parent_station]
+		if !ok || !valid[stop] || !valid[parent] {
+			continue
+		}
+
+		d := gtfs.HaversineDist(float64(stop.Lat), float64(stop.Lon), float64(parent.Lat), float64(parent.Lon))
+		if d > v.MaxParentDist {
+			findings = append(findings, Finding{
+				Code:     CodeStopFarFromParent,
+				Severity: SeverityWarning,
+				Filename: "stops.txt",
+				EntityId: stop.Id,
+				Field:    "parent_station",
+				Msg: fmt.Sprintf("Stop %s is %.0f m away from its parent station %s",
+					stop.Id, d, parent.Id),
+			})
+		}
+	}
+
+	return findings
+}
+
+func (v *GeoValidator) checkShapeDists(feed *Feed, valid map[*gtfs.Stop]bool) []Finding {
+	findings := make([]Finding, 0)
+
+	tripIds := make([]string, 0, len(feed.Trips))
+	for id := range feed.Trips {
+		tripIds = append(tripIds, id)
+	}
+	sort.Strings(tripIds)
+
+	// many trips share the same shape, only check each stop once per shape
+	type pair struct {
+		shape *gtfs.Shape
+		stop  *gtfs.Stop
+	}
+	checked := make(map[pair]bool)
+
+	for _, id := range tripIds {
+		trip := feed.Trips[id]
+		if trip.Shape == nil || len(trip.Shape.Points) == 0 {
+			continue
+		}
+
+		for _, st := range trip.StopTimes {
+			p := pair{trip.Shape, st.Stop}
+			if checked[p] || !valid[st.Stop] {
+				continue
+			}
+			checked[p] = true
+
+			d := shapeDist(trip.Shape, float64(st.Stop.Lat), float64(st.Stop.Lon))
+			if d > v.MaxStopShapeDist {
+				findings = append(findings, Finding{
+					Code:     CodeStopFarFromShape,
+					Severity: SeverityWarning,
+					Filename: "stop_times.txt",
+					EntityId: trip.Id,
+					Field:    "stop_id",
+					Msg: fmt.Sprintf("Stop %s is %.0f m away from shape %s of trip %s",
+						st.Stop.Id, d, trip.Shape.Id, trip.Id),
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+// Get the minimal distance in meters between a coordinate and a shape
+func shapeDist(shape *gtfs.Shape, lat float64, lon float64) float64 {
+	pts := shape.Points
+
+	if len(pts) == 1 {
+		return gtfs.HaversineDist(lat, lon, float64(pts[0].Lat), float64(pts[0].Lon))
+	}
+
+	min := math.Inf(1)
+	for i := 1; i < len(pts); i++ {
+		_, d := gtfs.ProjectOnSegment(lat, lon, float64(pts[i-1].Lat), float64(pts[i-1].Lon), float64(pts[i].Lat), float64(pts[i].Lon))
+		if d < min {
+			min = d
+		}
+	}
+
+	return min
+}
+
+func median(vals []float64) float64 {
+	s := make([]float64, len(vals))
+	copy(s, vals)
+	sort.Float64s(s)
+
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
@@ -0,0 +1,62 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type Color struct {
+	R uint8
+	G uint8
+	B uint8
+}
+
+// Parse a 6-digit hexadecimal GTFS color like "00FFAA"
+func ParseColor(s string) (Color, error) {
+	if len(s) != 6 {
+		return Color{}, errors.New(fmt.Sprintf("Expected 6-digit hex color, found '%s'", s))
+	}
+
+	num, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Color{}, errors.New(fmt.Sprintf("Expected 6-digit hex color, found '%s'", s))
+	}
+
+	return Color{R: uint8(num >> 16), G: uint8(num >> 8), B: uint8(num)}, nil
+}
+
+// Get the 6-digit hexadecimal representation of this color
+func (c Color) String() string {
+	return fmt.Sprintf("%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// Get the relative luminance of this color as defined by WCAG 2.0
+func (c Color) Luminance() float64 {
+	ch := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.03928 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+
+	return 0.2126*ch(c.R) + 0.7152*ch(c.G) + 0.0722*ch(c.B)
+}
+
+// Get the WCAG 2.0 contrast ratio between two colors, ranging from 1
+// (no contrast) to 21 (black on white)
+func ContrastRatio(a Color, b Color) float64 {
+	la, lb := a.Luminance(), b.Luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
@@ -12,3 +12,31 @@ type Frequency struct {
 	Headway_secs int
 	Exact_times  bool
 }
+
+// Get the start times of all trip instances described by this frequency,
+// in seconds since the start of the service day. Returns nil if the
+// frequency is invalid.
+func (f Frequency) GetStartTimes() []int {
+	start, err := ParseTime(f.Start_time)
+	if err != nil {
+		return nil
+	}
+
+	end, err := ParseTime(f.End_time)
+	if err != nil || f.Headway_secs <= 0 {
+		return nil
+	}
+
+	if end <= start {
+		return nil
+	}
+
+	ret := make([]int, 0, (end-start)/f.Headway_secs+1)
+
+	// end_time is the time at which the headway stops to be in effect
+	for t := start; t < end; t += f.Headway_secs {
+		ret = append(ret, t)
+	}
+
+	return ret
+}
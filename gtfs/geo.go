@@ -0,0 +1,157 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfs
+
+import (
+	"math"
+)
+
+// Mean earth radius in meters
+const EarthRadius = 6371008.8
+
+// Get the great-circle distance in meters between two WGS84 coordinates
+func HaversineDist(latA float64, lonA float64, latB float64, lonB float64) float64 {
+	dLat := (latB - latA) * math.Pi / 180
+	dLon := (lonB - lonA) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(latA*math.Pi/180)*math.Cos(latB*math.Pi/180)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadius * math.Asin(math.Sqrt(math.Min(1, a)))
+}
+
+// Project a coordinate onto the segment between A and B. Returns the
+// position of the projected point on the segment as a fraction between
+// 0 (at A) and 1 (at B), and the distance in meters between the
+// coordinate and the projected point.
+func ProjectOnSegment(lat float64, lon float64, latA float64, lonA float64, latB float64, lonB float64) (float64, float64) {
+	// use a local equirectangular projection around the coordinate,
+	// which is precise enough for the short segments found in shapes
+	kx := math.Cos(lat * math.Pi / 180)
+	ax, ay := (lonA-lon)*kx, latA-lat
+	bx, by := (lonB-lon)*kx, latB-lat
+	dx, dy := bx-ax, by-ay
+
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+	}
+
+	pLat := latA + t*(latB-latA)
+	pLon := lonA + t*(lonB-lonA)
+
+	return t, HaversineDist(lat, lon, pLat, pLon)
+}
+
+// A bounding box in WGS84 coordinates
+type BBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// Check whether a coordinate lies inside this bounding box
+func (b BBox) Contains(lat float64, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// Check whether this bounding box intersects bounding box o
+func (b BBox) Intersects(o BBox) bool {
+	return b.MinLat <= o.MaxLat && o.MinLat <= b.MaxLat && b.MinLon <= o.MaxLon && o.MinLon <= b.MaxLon
+}
+
+// Check whether the segment between A and B intersects this bounding box
+func (b BBox) IntersectsSegment(latA float64, lonA float64, latB float64, lonB float64) bool {
+	// Liang-Barsky clipping of the segment against the box
+	t0, t1 := 0.0, 1.0
+	dLat, dLon := latB-latA, lonB-lonA
+
+	clip := func(p float64, q float64) bool {
+		if p == 0 {
+			return q >= 0
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return false
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return false
+			}
+			t1 = math.Min(t1, r)
+		}
+		return true
+	}
+
+	return clip(-dLon, lonA-b.MinLon) && clip(dLon, b.MaxLon-lonA) &&
+		clip(-dLat, latA-b.MinLat) && clip(dLat, b.MaxLat-latA)
+}
+
+// Get the bounding box around a coordinate which contains all
+// coordinates within radius meters
+func RadiusBBox(lat float64, lon float64, radius float64) BBox {
+	dLat := radius / EarthRadius * 180 / math.Pi
+	dLon := 180.0
+	if c := math.Cos(lat * math.Pi / 180); c*180 > dLat {
+		dLon = dLat / c
+	}
+
+	return BBox{MinLat: lat - dLat, MinLon: lon - dLon, MaxLat: lat + dLat, MaxLon: lon + dLon}
+}
+
+// Split this bounding box at the antimeridian. A box whose longitudes
+// extend beyond -180 or 180 is returned as two boxes, wrapped around
+// to the other side. A box spanning all longitudes is returned as one.
+func (b BBox) SplitAtAntimeridian() []BBox {
+	if b.MaxLon-b.MinLon >= 360 {
+		return []BBox{{MinLat: b.MinLat, MinLon: -180, MaxLat: b.MaxLat, MaxLon: 180}}
+	}
+
+	if b.MinLon < -180 {
+		return []BBox{
+			{MinLat: b.MinLat, MinLon: b.MinLon + 360, MaxLat: b.MaxLat, MaxLon: 180},
+			{MinLat: b.MinLat, MinLon: -180, MaxLat: b.MaxLat, MaxLon: b.MaxLon},
+		}
+	}
+
+	if b.MaxLon > 180 {
+		return []BBox{
+			{MinLat: b.MinLat, MinLon: b.MinLon, MaxLat: b.MaxLat, MaxLon: 180},
+			{MinLat: b.MinLat, MinLon: -180, MaxLat: b.MaxLat, MaxLon: b.MaxLon - 360},
+		}
+	}
+
+	return []BBox{b}
+}
+
+// A WGS84 coordinate
+type Coord struct {
+	Lat float64
+	Lon float64
+}
+
+// A simple polygon, given by its vertices. The ring does not have to
+// be closed.
+type Polygon []Coord
+
+// Check whether a coordinate lies inside this polygon
+func (p Polygon) Contains(lat float64, lon float64) bool {
+	in := false
+
+	// count the crossings of a ray from the coordinate to the east
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Lat > lat) != (b.Lat > lat) && lon < (b.Lon-a.Lon)*(lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			in = !in
+		}
+	}
+
+	return in
+}
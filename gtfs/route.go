@@ -16,4 +16,22 @@ type Route struct {
 	Url        string
 	Color      string
 	Text_color string
-}
\ No newline at end of file
+}
+
+// Get the parsed route color. Defaults to white if no valid
+// color is set.
+func (r Route) RGB() Color {
+	if c, err := ParseColor(r.Color); err == nil {
+		return c
+	}
+	return Color{255, 255, 255}
+}
+
+// Get the parsed route text color. Defaults to black if no valid
+// color is set.
+func (r Route) TextRGB() Color {
+	if c, err := ParseColor(r.Text_color); err == nil {
+		return c
+	}
+	return Color{0, 0, 0}
+}
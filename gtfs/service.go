@@ -7,6 +7,7 @@
 package gtfs
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -45,4 +46,90 @@ func (s Service) GetExceptionTypeOn(d Date) int8 {
 
 func (d Date) GetTime() time.Time {
 	return time.Date(int(d.Year), time.Month(d.Month), int(d.Day), 12, 0, 0, 0, time.UTC)
-}
\ No newline at end of file
+}
+
+// Get the Date of a time.Time, in the time's location
+func GetDate(t time.Time) Date {
+	return Date{Day: int8(t.Day()), Month: int8(t.Month()), Year: int16(t.Year())}
+}
+
+// Check whether this date is unset
+func (d Date) IsEmpty() bool {
+	return d.Day == 0 && d.Month == 0 && d.Year == 0
+}
+
+// Check whether this date lies before date o
+func (d Date) Before(o Date) bool {
+	if d.Year != o.Year {
+		return d.Year < o.Year
+	}
+	if d.Month != o.Month {
+		return d.Month < o.Month
+	}
+	return d.Day < o.Day
+}
+
+// Check whether this date lies after date o
+func (d Date) After(o Date) bool {
+	return o.Before(d)
+}
+
+// Get the date n days after this date (or before, if n is negative)
+func (d Date) AddDays(n int) Date {
+	return GetDate(d.GetTime().AddDate(0, 0, n))
+}
+
+// Get the YYYYMMDD representation of this date
+func (d Date) String() string {
+	return fmt.Sprintf("%04d%02d%02d", d.Year, d.Month, d.Day)
+}
+
+// Get the first date on which this service is active, or an empty
+// date if the service is never active
+func (s Service) GetFirstActiveDate() Date {
+	first, _ := s.getActiveRange()
+	return first
+}
+
+// Get the last date on which this service is active, or an empty
+// date if the service is never active
+func (s Service) GetLastActiveDate() Date {
+	_, last := s.getActiveRange()
+	return last
+}
+
+func (s Service) getActiveRange() (Date, Date) {
+	var start, end Date
+
+	// the calendar range, extended by all exception dates
+	if !s.Start_date.IsEmpty() && !s.End_date.IsEmpty() {
+		start, end = s.Start_date, s.End_date
+	}
+
+	for _, e := range s.Exceptions {
+		if start.IsEmpty() || e.Date.Before(start) {
+			start = e.Date
+		}
+		if end.IsEmpty() || e.Date.After(end) {
+			end = e.Date
+		}
+	}
+
+	if start.IsEmpty() {
+		return Date{}, Date{}
+	}
+
+	for !start.After(end) && !s.IsActiveOn(start) {
+		start = start.AddDays(1)
+	}
+
+	if start.After(end) {
+		return Date{}, Date{}
+	}
+
+	for !s.IsActiveOn(end) {
+		end = end.AddDays(-1)
+	}
+
+	return start, end
+}
@@ -7,6 +7,8 @@
 package gtfs
 
 import (
+	"math"
+	"sort"
 	"strconv"
 )
 
@@ -55,3 +57,357 @@ func (shapePoints ShapePoints) Less(i, j int) bool {
 func (shapePoints ShapePoints) Swap(i, j int) {
 	shapePoints[i], shapePoints[j] = shapePoints[j], shapePoints[i]
 }
+
+// Get the length of the polyline through these points in meters
+func (shapePoints ShapePoints) Length() float64 {
+	dists := shapePoints.getDists()
+	if len(dists) == 0 {
+		return 0
+	}
+	return dists[len(dists)-1]
+}
+
+// Get the distance in meters from the first point to each point
+func (shapePoints ShapePoints) getDists() []float64 {
+	ret := make([]float64, len(shapePoints))
+	for i := 1; i < len(shapePoints); i++ {
+		a, b := shapePoints[i-1], shapePoints[i]
+		ret[i] = ret[i-1] + HaversineDist(float64(a.Lat), float64(a.Lon), float64(b.Lat), float64(b.Lon))
+	}
+	return ret
+}
+
+// Get the coordinate at dist meters along the polyline through these
+// points. The distance is clamped to the polyline.
+func (shapePoints ShapePoints) PointAt(dist float64) (float64, float64) {
+	if len(shapePoints) == 0 {
+		return 0, 0
+	}
+
+	dists := shapePoints.getDists()
+	i := sort.SearchFloat64s(dists, dist)
+
+	if i == 0 {
+		return float64(shapePoints[0].Lat), float64(shapePoints[0].Lon)
+	}
+	if i == len(dists) {
+		last := shapePoints[len(shapePoints)-1]
+		return float64(last.Lat), float64(last.Lon)
+	}
+
+	a, b := shapePoints[i-1], shapePoints[i]
+	t := (dist - dists[i-1]) / (dists[i] - dists[i-1])
+
+	return float64(a.Lat) + t*float64(b.Lat-a.Lat), float64(a.Lon) + t*float64(b.Lon-a.Lon)
+}
+
+// Get a simplified copy of these points, using the Douglas-Peucker
+// algorithm. No removed point is farther than tolerance meters from the
+// simplified polyline. The returned slice shares the points.
+func (shapePoints ShapePoints) Simplify(tolerance float64) ShapePoints {
+	if len(shapePoints) < 3 {
+		return append(ShapePoints{}, shapePoints...)
+	}
+
+	keep := make([]bool, len(shapePoints))
+	keep[0], keep[len(shapePoints)-1] = true, true
+
+	stack := [][2]int{{0, len(shapePoints) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		a, b := shapePoints[first], shapePoints[last]
+		maxDist, maxI := 0.0, -1
+
+		for i := first + 1; i < last; i++ {
+			p := shapePoints[i]
+			_, d := ProjectOnSegment(float64(p.Lat), float64(p.Lon), float64(a.Lat), float64(a.Lon), float64(b.Lat), float64(b.Lon))
+			if d > maxDist {
+				maxDist, maxI = d, i
+			}
+		}
+
+		if maxI >= 0 && maxDist > tolerance {
+			keep[maxI] = true
+			stack = append(stack, [2]int{first, maxI}, [2]int{maxI, last})
+		}
+	}
+
+	ret := make(ShapePoints, 0)
+	for i, p := range shapePoints {
+		if keep[i] {
+			ret = append(ret, p)
+		}
+	}
+
+	return ret
+}
+
+// Get a reversed copy of these points. Sequences are renumbered and
+// traveled distances are measured from the new start.
+func (shapePoints ShapePoints) Reverse() ShapePoints {
+	ret := make(ShapePoints, len(shapePoints))
+	if len(shapePoints) == 0 {
+		return ret
+	}
+
+	total := shapePoints[len(shapePoints)-1].Dist_traveled
+
+	for i, p := range shapePoints {
+		ret[len(shapePoints)-1-i] = &ShapePoint{
+			Lat:           p.Lat,
+			Lon:           p.Lon,
+			Sequence:      len(shapePoints) - 1 - i,
+			Dist_traveled: total - p.Dist_traveled,
+		}
+	}
+
+	return ret
+}
+
+// Project a coordinate onto the polyline through these points, at or
+// after segment seg and fraction t on it. Returns the segment, the
+// fraction on it and the distance in meters from the coordinate.
+func (shapePoints ShapePoints) project(lat float64, lon float64, seg int, t float64) (int, float64, float64) {
+	bestSeg, bestT, bestDist := seg, t, math.Inf(1)
+
+	for i := seg; i < len(shapePoints)-1; i++ {
+		a, b := shapePoints[i], shapePoints[i+1]
+		pt, d := ProjectOnSegment(lat, lon, float64(a.Lat), float64(a.Lon), float64(b.Lat), float64(b.Lon))
+		if i == seg && pt < t {
+			pt = t
+			pLat := float64(a.Lat) + t*float64(b.Lat-a.Lat)
+			pLon := float64(a.Lon) + t*float64(b.Lon-a.Lon)
+			d = HaversineDist(lat, lon, pLat, pLon)
+		}
+		if d < bestDist {
+			bestSeg, bestT, bestDist = i, pt, d
+		}
+	}
+
+	return bestSeg, bestT, bestDist
+}
+
+// Get the part of this shape between two stops, which are projected
+// onto the shape in order. The cut points are interpolated, sequences
+// are renumbered and traveled distances are measured from the first
+// stop.
+func (shape *Shape) Split(from *Stop, to *Stop) ShapePoints {
+	pts := shape.Points
+	if len(pts) < 2 {
+		return append(ShapePoints{}, pts...)
+	}
+
+	segA, tA, _ := pts.project(float64(from.Lat), float64(from.Lon), 0, 0)
+	segB, tB, _ := pts.project(float64(to.Lat), float64(to.Lon), segA, tA)
+
+	ret := pts.getPart(segA, tA, segB, tB)
+
+	start := ret[0].Dist_traveled
+	for _, p := range ret {
+		p.Dist_traveled -= start
+	}
+
+	return ret
+}
+
+// Get the part of the polyline through these points between from and
+// to meters along it. The cut points are interpolated, sequences are
+// renumbered and traveled distances are kept.
+func (shapePoints ShapePoints) Cut(from float64, to float64) ShapePoints {
+	if len(shapePoints) < 2 {
+		return append(ShapePoints{}, shapePoints...)
+	}
+
+	dists := shapePoints.getDists()
+
+	locate := func(d float64) (int, float64) {
+		seg := sort.SearchFloat64s(dists, d) - 1
+		if seg < 0 {
+			return 0, 0
+		}
+		if seg >= len(dists)-1 {
+			return len(dists) - 2, 1
+		}
+		return seg, (d - dists[seg]) / (dists[seg+1] - dists[seg])
+	}
+
+	segA, tA := locate(from)
+	segB, tB := locate(to)
+
+	return shapePoints.getPart(segA, tA, segB, tB)
+}
+
+// Get copies of the points between fraction tA on segment segA and
+// fraction tB on segment segB, with interpolated end points and
+// renumbered sequences
+func (shapePoints ShapePoints) getPart(segA int, tA float64, segB int, tB float64) ShapePoints {
+	interpolate := func(seg int, t float64) *ShapePoint {
+		a, b := shapePoints[seg], shapePoints[seg+1]
+		return &ShapePoint{
+			Lat:           a.Lat + float32(t)*(b.Lat-a.Lat),
+			Lon:           a.Lon + float32(t)*(b.Lon-a.Lon),
+			Dist_traveled: a.Dist_traveled + float32(t)*(b.Dist_traveled-a.Dist_traveled),
+		}
+	}
+
+	ret := ShapePoints{interpolate(segA, tA)}
+	for i := segA + 1; i <= segB; i++ {
+		p := *shapePoints[i]
+		ret = append(ret, &p)
+	}
+	ret = append(ret, interpolate(segB, tB))
+
+	for i, p := range ret {
+		p.Sequence = i
+	}
+
+	return ret
+}
+
+// Set the traveled distance of each point to its distance in meters
+// from the first point
+func (shapePoints ShapePoints) ComputeDistTraveled() {
+	for i, d := range shapePoints.getDists() {
+		shapePoints[i].Dist_traveled = float32(d)
+	}
+}
+
+// A possible projection of a stop onto a shape
+type stopProjection struct {
+	pos  float64
+	dist float64
+	cost float64
+	prev int
+}
+
+// Project stops in order onto this shape. Returns for each stop the
+// distance in meters along the shape and its distance in meters from
+// the shape. Projections never move backwards, so shapes passing a stop
+// twice are handled. Positions on the shape farther than maxDist meters
+// from a stop are only considered if there is no closer one.
+func (shape *Shape) ProjectStops(stops []*Stop, maxDist float64) ([]float64, []float64) {
+	pos := make([]float64, len(stops))
+	offsets := make([]float64, len(stops))
+	pts := shape.Points
+
+	if len(pts) == 0 {
+		return pos, offsets
+	}
+
+	if len(pts) == 1 {
+		for i, s := range stops {
+			offsets[i] = HaversineDist(float64(s.Lat), float64(s.Lon), float64(pts[0].Lat), float64(pts[0].Lon))
+		}
+		return pos, offsets
+	}
+
+	dists := pts.getDists()
+	cands := make([][]stopProjection, len(stops))
+
+	for i, s := range stops {
+		cands[i] = pts.getProjections(float64(s.Lat), float64(s.Lon), dists, maxDist)
+
+		if i == 0 {
+			for j := range cands[i] {
+				cands[i][j].cost = cands[i][j].dist
+			}
+			continue
+		}
+
+		// both candidate lists are sorted by position, so the cheapest
+		// predecessor not after a candidate is a running minimum
+		prev := cands[i-1]
+		best, bestCost := -1, math.Inf(1)
+		k := 0
+		for j := range cands[i] {
+			c := &cands[i][j]
+			for ; k < len(prev) && prev[k].pos <= c.pos; k++ {
+				if prev[k].cost < bestCost {
+					best, bestCost = k, prev[k].cost
+				}
+			}
+			c.prev = best
+			c.cost = bestCost + c.dist
+		}
+
+		// no candidate lies after any predecessor, project the stop
+		// onto the shape after the cheapest predecessor
+		if best < 0 {
+			for k := range prev {
+				if best < 0 || prev[k].cost < prev[best].cost {
+					best = k
+				}
+			}
+
+			seg := sort.SearchFloat64s(dists, prev[best].pos) - 1
+			if seg < 0 {
+				seg = 0
+			}
+			if seg > len(dists)-2 {
+				seg = len(dists) - 2
+			}
+			t := 0.0
+			if l := dists[seg+1] - dists[seg]; l > 0 {
+				t = math.Min(math.Max((prev[best].pos-dists[seg])/l, 0), 1)
+			}
+
+			seg, t, d := pts.project(float64(s.Lat), float64(s.Lon), seg, t)
+			p := dists[seg] + t*(dists[seg+1]-dists[seg])
+			cands[i] = []stopProjection{{pos: p, dist: d, cost: prev[best].cost + d, prev: best}}
+		}
+	}
+
+	// trace back the cheapest sequence of projections
+	best := 0
+	last := cands[len(cands)-1]
+	for j := range last {
+		if last[j].cost < last[best].cost {
+			best = j
+		}
+	}
+
+	for i := len(stops) - 1; i >= 0; i-- {
+		pos[i] = cands[i][best].pos
+		offsets[i] = cands[i][best].dist
+		best = cands[i][best].prev
+	}
+
+	return pos, offsets
+}
+
+// Get the candidate projections of a coordinate onto the polyline,
+// sorted by position: the local distance minima not farther than
+// maxDist, or the global minimum if there are none
+func (shapePoints ShapePoints) getProjections(lat float64, lon float64, dists []float64, maxDist float64) []stopProjection {
+	n := len(shapePoints) - 1
+	segs := make([]stopProjection, n)
+
+	for i := 0; i < n; i++ {
+		a, b := shapePoints[i], shapePoints[i+1]
+		t, d := ProjectOnSegment(lat, lon, float64(a.Lat), float64(a.Lon), float64(b.Lat), float64(b.Lon))
+		segs[i] = stopProjection{pos: dists[i] + t*(dists[i+1]-dists[i]), dist: d, prev: -1}
+	}
+
+	ret := make([]stopProjection, 0)
+	min := 0
+
+	for i := 0; i < n; i++ {
+		if segs[i].dist < segs[min].dist {
+			min = i
+		}
+		if segs[i].dist > maxDist {
+			continue
+		}
+		if (i == 0 || segs[i].dist < segs[i-1].dist) && (i == n-1 || segs[i].dist <= segs[i+1].dist) {
+			ret = append(ret, segs[i])
+		}
+	}
+
+	if len(ret) == 0 {
+		ret = append(ret, segs[min])
+	}
+
+	return ret
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfs
+
+import (
+	"math"
+	"testing"
+)
+
+// A shape along the parallel at 47° from 7.0 to 7.3, with points every
+// 0.1°, which is about 7.6 km
+func newTestShape() *Shape {
+	shape := &Shape{Id: "SH"}
+	for i := 0; i < 4; i++ {
+		shape.Points = append(shape.Points, &ShapePoint{Lat: 47, Lon: 7 + float32(i)*0.1, Sequence: i})
+	}
+	shape.Points.ComputeDistTraveled()
+	return shape
+}
+
+func checkDist(t *testing.T, what string, got float64, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1 {
+		t.Errorf("got %s %f, want %f", what, got, want)
+	}
+}
+
+func TestShapeLength(t *testing.T) {
+	shape := newTestShape()
+	checkDist(t, "length", shape.Points.Length(), HaversineDist(47, 7, 47, 7.3))
+
+	lat, lon := shape.Points.PointAt(shape.Points.Length() / 2)
+	checkDist(t, "distance of the middle point", HaversineDist(lat, lon, 47, 7.15), 0)
+
+	// distances are clamped to the shape
+	if lat, lon := shape.Points.PointAt(-10); lat != 47 || lon != 7 {
+		t.Errorf("got %f,%f before the start, want the first point", lat, lon)
+	}
+}
+
+func TestShapeSimplifyReverse(t *testing.T) {
+	shape := newTestShape()
+
+	// all points lie on a straight line
+	if pts := shape.Points.Simplify(1); len(pts) != 2 {
+		t.Errorf("got %d points, want 2", len(pts))
+	}
+
+	rev := shape.Points.Reverse()
+	if rev[0].Lon != shape.Points[3].Lon || rev[0].Sequence != 0 || rev[0].Dist_traveled != 0 {
+		t.Errorf("got first reversed point %v, want the last point at distance 0", *rev[0])
+	}
+	if rev[3].Dist_traveled != shape.Points[3].Dist_traveled {
+		t.Errorf("got last reversed distance %f, want %f", rev[3].Dist_traveled, shape.Points[3].Dist_traveled)
+	}
+}
+
+func TestShapeCut(t *testing.T) {
+	shape := newTestShape()
+	seg := shape.Points.Length() / 3
+
+	pts := shape.Points.Cut(seg/2, 1.5*seg)
+	if len(pts) != 3 {
+		t.Fatalf("got %d points, want 3", len(pts))
+	}
+	checkDist(t, "length", pts.Length(), seg)
+	checkDist(t, "first distance", float64(pts[0].Dist_traveled), seg/2)
+	for i, p := range pts {
+		if p.Sequence != i {
+			t.Errorf("got sequence %d for point %d", p.Sequence, i)
+		}
+	}
+
+	// the original points are not modified
+	if shape.Points[1].Sequence != 1 {
+		t.Error("got the original points modified")
+	}
+
+	// cuts beyond the shape are clamped
+	checkDist(t, "clamped length", shape.Points.Cut(-100, 1e9).Length(), shape.Points.Length())
+}
+
+func TestShapeSplit(t *testing.T) {
+	shape := newTestShape()
+
+	// stops slightly off the shape
+	from := &Stop{Id: "A", Lat: 47.001, Lon: 7.05}
+	to := &Stop{Id: "B", Lat: 46.999, Lon: 7.25}
+
+	pts := shape.Split(from, to)
+	checkDist(t, "length", pts.Length(), HaversineDist(47, 7.05, 47, 7.25))
+	if pts[0].Dist_traveled != 0 {
+		t.Errorf("got first distance %f, want 0", pts[0].Dist_traveled)
+	}
+	checkDist(t, "last distance", float64(pts[len(pts)-1].Dist_traveled), pts.Length())
+}
+
+func TestProjectStops(t *testing.T) {
+	shape := newTestShape()
+	stops := []*Stop{
+		{Id: "A", Lat: 47.001, Lon: 7.0},
+		{Id: "B", Lat: 47.001, Lon: 7.15},
+		{Id: "C", Lat: 46.999, Lon: 7.3},
+	}
+
+	pos, offsets := shape.ProjectStops(stops, 150)
+	checkDist(t, "position of A", pos[0], 0)
+	checkDist(t, "position of B", pos[1], shape.Points.Length()/2)
+	checkDist(t, "position of C", pos[2], shape.Points.Length())
+	for i, o := range offsets {
+		checkDist(t, "offset of "+stops[i].Id, o, HaversineDist(47, 7, 47.001, 7))
+	}
+}
+
+func TestProjectStopsLoop(t *testing.T) {
+	// a shape going out and back, passing each stop twice
+	shape := newTestShape()
+	back := shape.Points.Reverse()
+	for _, p := range back[1:] {
+		shape.Points = append(shape.Points, &ShapePoint{Lat: p.Lat + 0.0005, Lon: p.Lon, Sequence: len(shape.Points)})
+	}
+	length := shape.Points.Length()
+
+	a := &Stop{Id: "A", Lat: 47, Lon: 7.1}
+	b := &Stop{Id: "B", Lat: 47, Lon: 7.2}
+	pos, _ := shape.ProjectStops([]*Stop{a, b, a}, 150)
+
+	checkDist(t, "position of A", pos[0], length/6)
+	checkDist(t, "position of B", pos[1], length/3)
+	if pos[2] < length/2 {
+		t.Errorf("got position %f of A on the way back, want more than %f", pos[2], length/2)
+	}
+}
+
+func TestProjectStopsBackwards(t *testing.T) {
+	shape := newTestShape()
+
+	// C lies behind the end of the shape, B before it, so B is projected
+	// after C
+	b := &Stop{Id: "B", Lat: 47, Lon: 7.15}
+	c := &Stop{Id: "C", Lat: 47, Lon: 7.4}
+	pos, offsets := shape.ProjectStops([]*Stop{c, b}, 150)
+
+	checkDist(t, "position of C", pos[0], shape.Points.Length())
+	checkDist(t, "position of B", pos[1], shape.Points.Length())
+	checkDist(t, "offset of B", offsets[1], HaversineDist(47, 7.15, 47, 7.3))
+}
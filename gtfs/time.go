@@ -0,0 +1,42 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Get the number of seconds since the start of the service day for a
+// GTFS time string in the format H:MM:SS. Hours may exceed 23 for
+// trips running past midnight.
+func ParseTime(t string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(t), ":")
+
+	if len(parts) != 3 {
+		return 0, errors.New(fmt.Sprintf("Expected time in format HH:MM:SS, found '%s'", t))
+	}
+
+	var secs [3]int
+	for i, p := range parts {
+		num, err := strconv.Atoi(p)
+		if err != nil || num < 0 || (i > 0 && (num > 59 || len(p) != 2)) {
+			return 0, errors.New(fmt.Sprintf("Expected time in format HH:MM:SS, found '%s'", t))
+		}
+		secs[i] = num
+	}
+
+	return secs[0]*3600 + secs[1]*60 + secs[2], nil
+}
+
+// Get a GTFS time string (HH:MM:SS) for a number of seconds since the
+// start of the service day
+func FormatTime(secs int) string {
+	return fmt.Sprintf("%02d:%02d:%02d", secs/3600, (secs/60)%60, secs%60)
+}
@@ -6,6 +6,10 @@
 
 package gtfs
 
+import (
+	"strconv"
+)
+
 type Trip struct {
 	Id                    string
 	Route                 *Route
@@ -20,3 +24,65 @@ type Trip struct {
 	StopTimes             StopTimes
 	Frequencies           []*Frequency
 }
+
+// Get the concrete trip instances described by the frequencies of this
+// trip, with stop times shifted to the start time of each instance. The
+// instances have no frequencies and are named <trip id>_<start time>,
+// with a further _<n> suffix if frequencies overlap. If the frequencies
+// are not exact, the stop times of the instances are marked as
+// approximate. Returns nil if the frequencies yield no instances.
+func (t *Trip) ExpandFrequencies() []*Trip {
+	if len(t.Frequencies) == 0 || len(t.StopTimes) == 0 {
+		return nil
+	}
+
+	first, err := ParseTime(t.StopTimes[0].Departure_time)
+	if err != nil {
+		if first, err = ParseTime(t.StopTimes[0].Arrival_time); err != nil {
+			return nil
+		}
+	}
+
+	ret := make([]*Trip, 0)
+	ids := make(map[string]bool)
+
+	for _, f := range t.Frequencies {
+		for _, start := range f.GetStartTimes() {
+			inst := *t
+			inst.Id = t.Id + "_" + FormatTime(start)
+			for n := 2; ids[inst.Id]; n++ {
+				inst.Id = t.Id + "_" + FormatTime(start) + "_" + strconv.Itoa(n)
+			}
+			ids[inst.Id] = true
+			inst.Frequencies = nil
+			inst.StopTimes = make(StopTimes, len(t.StopTimes))
+
+			for i, st := range t.StopTimes {
+				shifted := *st
+				shifted.Arrival_time = shiftTime(st.Arrival_time, start-first)
+				shifted.Departure_time = shiftTime(st.Departure_time, start-first)
+				if !f.Exact_times {
+					shifted.Timepoint = false
+				}
+				inst.StopTimes[i] = &shifted
+			}
+
+			ret = append(ret, &inst)
+		}
+	}
+
+	if len(ret) == 0 {
+		return nil
+	}
+
+	return ret
+}
+
+// Shift a GTFS time string by secs seconds, empty or invalid
+// times are returned unchanged
+func shiftTime(t string, secs int) string {
+	if parsed, err := ParseTime(t); err == nil {
+		return FormatTime(parsed + secs)
+	}
+	return t
+}
@@ -0,0 +1,182 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"encoding/csv"
+	"github.com/geops/gtfsparser/gtfs"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Departure counts and headways at a stop. Headways are given in
+// seconds and are 0 if there are less than two departures.
+type HeadwayProfile struct {
+	Departures        int
+	DeparturesPerHour map[int]int // keyed by hour of the service day
+	MaxHeadway        int
+	MedianHeadway     int
+}
+
+// The headway profile of a stop on a single date, for all routes
+// together and for each route
+type StopProfile struct {
+	Stop *gtfs.Stop
+	Date gtfs.Date
+	HeadwayProfile
+	Routes map[*gtfs.Route]*HeadwayProfile
+}
+
+// Get the headway profile of every stop served on date, sorted by stop
+// ID. Departures of frequency-based trips are included, departures of
+// trips ending at a stop are not.
+func (feed *Feed) GetStopProfiles(date gtfs.Date) []*StopProfile {
+	ret := make([]*StopProfile, 0)
+
+	ids := make([]string, 0, len(feed.Stops))
+	for id := range feed.Stops {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if p := feed.GetStopProfile(feed.Stops[id], date); p.Departures > 0 {
+			ret = append(ret, p)
+		}
+	}
+
+	return ret
+}
+
+// Get the headway profile of a stop on date
+func (feed *Feed) GetStopProfile(stop *gtfs.Stop, date gtfs.Date) *StopProfile {
+	all := make([]int, 0)
+	byRoute := make(map[*gtfs.Route][]int)
+
+	for _, tst := range feed.StopTimesAtStop(stop) {
+		trip, st := tst.Trip, tst.StopTime
+
+		if st == trip.StopTimes[len(trip.StopTimes)-1] || !trip.Service.IsActiveOn(date) {
+			continue
+		}
+
+		dep, err := gtfs.ParseTime(st.Departure_time)
+		if err != nil {
+			if dep, err = gtfs.ParseTime(st.Arrival_time); err != nil {
+				continue
+			}
+		}
+
+		deps := []int{dep}
+		if len(trip.Frequencies) > 0 {
+			deps = getFrequencyDepartures(trip, dep)
+		}
+
+		all = append(all, deps...)
+		byRoute[trip.Route] = append(byRoute[trip.Route], deps...)
+	}
+
+	p := &StopProfile{
+		Stop:           stop,
+		Date:           date,
+		HeadwayProfile: *newHeadwayProfile(all),
+		Routes:         make(map[*gtfs.Route]*HeadwayProfile),
+	}
+
+	for route, deps := range byRoute {
+		p.Routes[route] = newHeadwayProfile(deps)
+	}
+
+	return p
+}
+
+func newHeadwayProfile(deps []int) *HeadwayProfile {
+	sort.Ints(deps)
+
+	p := &HeadwayProfile{
+		Departures:        len(deps),
+		DeparturesPerHour: make(map[int]int),
+	}
+
+	for _, d := range deps {
+		p.DeparturesPerHour[d/3600]++
+	}
+
+	if len(deps) < 2 {
+		return p
+	}
+
+	headways := make([]int, len(deps)-1)
+	for i := 1; i < len(deps); i++ {
+		headways[i-1] = deps[i] - deps[i-1]
+	}
+	sort.Ints(headways)
+
+	p.MaxHeadway = headways[len(headways)-1]
+	if n := len(headways); n%2 == 1 {
+		p.MedianHeadway = headways[n/2]
+	} else {
+		p.MedianHeadway = (headways[n/2-1] + headways[n/2]) / 2
+	}
+
+	return p
+}
+
+// Write stop profiles as CSV. Each stop gets one row for all routes,
+// with an empty route_id, followed by one row per route. The hour
+// columns hold the number of departures in that hour of the service day.
+func WriteStopProfilesCsv(w io.Writer, profiles []*StopProfile) error {
+	maxHour := 23
+	for _, p := range profiles {
+		for h := range p.DeparturesPerHour {
+			if h > maxHour {
+				maxHour = h
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+
+	header := []string{"stop_id", "date", "route_id", "departures", "max_headway", "median_headway"}
+	for h := 0; h <= maxHour; h++ {
+		header = append(header, "h"+strconv.Itoa(h))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	row := func(p *StopProfile, routeId string, hp *HeadwayProfile) []string {
+		r := []string{p.Stop.Id, p.Date.String(), routeId, strconv.Itoa(hp.Departures),
+			strconv.Itoa(hp.MaxHeadway), strconv.Itoa(hp.MedianHeadway)}
+		for h := 0; h <= maxHour; h++ {
+			r = append(r, strconv.Itoa(hp.DeparturesPerHour[h]))
+		}
+		return r
+	}
+
+	for _, p := range profiles {
+		if err := cw.Write(row(p, "", &p.HeadwayProfile)); err != nil {
+			return err
+		}
+
+		routes := make([]*gtfs.Route, 0, len(p.Routes))
+		for r := range p.Routes {
+			routes = append(routes, r)
+		}
+		sort.Slice(routes, func(i, j int) bool { return routes[i].Id < routes[j].Id })
+
+		for _, r := range routes {
+			if err := cw.Write(row(p, r.Id, p.Routes[r])); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
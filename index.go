@@ -0,0 +1,150 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+)
+
+// A stop time together with the trip it belongs to
+type TripStopTime struct {
+	Trip     *gtfs.Trip
+	StopTime *gtfs.StopTime
+}
+
+type feedIndex struct {
+	tripsByRoute    map[*gtfs.Route][]*gtfs.Trip
+	tripsByService  map[*gtfs.Service][]*gtfs.Trip
+	tripsByShape    map[*gtfs.Shape][]*gtfs.Trip
+	stopTimesByStop map[*gtfs.Stop][]TripStopTime
+	routesByAgency  map[*gtfs.Agency][]*gtfs.Route
+	routesByStop    map[*gtfs.Stop][]*gtfs.Route
+}
+
+// Build the reverse indexes used by TripsForRoute, StopTimesAtStop etc.
+// The indexes are built automatically on first use, but building them
+// right after parsing avoids the delay on the first query.
+func (feed *Feed) BuildIndex() {
+	idx := &feedIndex{
+		tripsByRoute:    make(map[*gtfs.Route][]*gtfs.Trip),
+		tripsByService:  make(map[*gtfs.Service][]*gtfs.Trip),
+		tripsByShape:    make(map[*gtfs.Shape][]*gtfs.Trip),
+		stopTimesByStop: make(map[*gtfs.Stop][]TripStopTime),
+		routesByAgency:  make(map[*gtfs.Agency][]*gtfs.Route),
+		routesByStop:    make(map[*gtfs.Stop][]*gtfs.Route),
+	}
+
+	// iterate in a stable order to get reproducible results
+	tripIds := make([]string, 0, len(feed.Trips))
+	for id := range feed.Trips {
+		tripIds = append(tripIds, id)
+	}
+	sort.Strings(tripIds)
+
+	routeSeen := make(map[*gtfs.Stop]map[*gtfs.Route]bool)
+
+	for _, id := range tripIds {
+		trip := feed.Trips[id]
+
+		idx.tripsByRoute[trip.Route] = append(idx.tripsByRoute[trip.Route], trip)
+		idx.tripsByService[trip.Service] = append(idx.tripsByService[trip.Service], trip)
+		if trip.Shape != nil {
+			idx.tripsByShape[trip.Shape] = append(idx.tripsByShape[trip.Shape], trip)
+		}
+
+		for _, st := range trip.StopTimes {
+			idx.stopTimesByStop[st.Stop] = append(idx.stopTimesByStop[st.Stop], TripStopTime{trip, st})
+
+			if routeSeen[st.Stop] == nil {
+				routeSeen[st.Stop] = make(map[*gtfs.Route]bool)
+			}
+			if !routeSeen[st.Stop][trip.Route] {
+				routeSeen[st.Stop][trip.Route] = true
+				idx.routesByStop[st.Stop] = append(idx.routesByStop[st.Stop], trip.Route)
+			}
+		}
+	}
+
+	for _, routes := range idx.routesByStop {
+		sort.Slice(routes, func(i, j int) bool { return routes[i].Id < routes[j].Id })
+	}
+
+	routeIds := make([]string, 0, len(feed.Routes))
+	for id := range feed.Routes {
+		routeIds = append(routeIds, id)
+	}
+	sort.Strings(routeIds)
+
+	for _, id := range routeIds {
+		route := feed.Routes[id]
+		if agency := feed.getRouteAgency(route); agency != nil {
+			idx.routesByAgency[agency] = append(idx.routesByAgency[agency], route)
+		}
+	}
+
+	feed.index = idx
+}
+
+// Get the agency of a route. Routes without an agency belong to the
+// feed's only agency, nil is returned if the feed has several.
+func (feed *Feed) getRouteAgency(route *gtfs.Route) *gtfs.Agency {
+	if route.Agency != nil || len(feed.Agencies) != 1 {
+		return route.Agency
+	}
+
+	for _, a := range feed.Agencies {
+		return a
+	}
+
+	return nil
+}
+
+// Drop the reverse and spatial indexes. Must be called after the feed was modified
+// by directly changing its maps or entities, functions of this package
+// which modify the feed do this themselves.
+func (feed *Feed) InvalidateIndex() {
+	feed.index = nil
+	feed.spatialIndex = nil
+}
+
+func (feed *Feed) getIndex() *feedIndex {
+	if feed.index == nil {
+		feed.BuildIndex()
+	}
+	return feed.index
+}
+
+// Get all trips of a route, sorted by ID
+func (feed *Feed) TripsForRoute(route *gtfs.Route) []*gtfs.Trip {
+	return feed.getIndex().tripsByRoute[route]
+}
+
+// Get all trips running on a service, sorted by ID
+func (feed *Feed) TripsForService(service *gtfs.Service) []*gtfs.Trip {
+	return feed.getIndex().tripsByService[service]
+}
+
+// Get all trips using a shape, sorted by ID
+func (feed *Feed) TripsForShape(shape *gtfs.Shape) []*gtfs.Trip {
+	return feed.getIndex().tripsByShape[shape]
+}
+
+// Get all stop times at a stop, sorted by trip ID and stop sequence
+func (feed *Feed) StopTimesAtStop(stop *gtfs.Stop) []TripStopTime {
+	return feed.getIndex().stopTimesByStop[stop]
+}
+
+// Get all routes of an agency, sorted by ID
+func (feed *Feed) RoutesForAgency(agency *gtfs.Agency) []*gtfs.Route {
+	return feed.getIndex().routesByAgency[agency]
+}
+
+// Get all routes serving a stop, sorted by ID
+func (feed *Feed) RoutesAtStop(stop *gtfs.Stop) []*gtfs.Route {
+	return feed.getIndex().routesByStop[stop]
+}
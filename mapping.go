@@ -50,7 +50,7 @@ func createFrequency(r map[string]string, trips map[string]*gtfs.Trip) {
 	if val, ok := trips[tripid]; ok {
 		trip = val
 	} else {
-		panic(errors.New("No trip with id " + r["trip_id"] + " found."))
+		panic(newFieldError(ErrUnknownReference, "trip_id", tripid, "No trip with id "+tripid+" found."))
 	}
 
 	a.Exact_times = getBool("exact_times", r, false)
@@ -70,7 +70,7 @@ func createRoute(r map[string]string, agencies map[string]*gtfs.Agency) *gtfs.Ro
 		if val, ok := agencies[aId]; ok {
 			a.Agency = val
 		} else {
-			panic(errors.New("No agency with id " + aId + " found."))
+			panic(newFieldError(ErrUnknownReference, "agency_id", aId, "No agency with id "+aId+" found."))
 		}
 	}
 
@@ -155,16 +155,20 @@ func createStopTime(r map[string]string, stops map[string]*gtfs.Stop, trips map[
 	a := new(gtfs.StopTime)
 	var trip *gtfs.Trip
 
-	if val, ok := trips[getString("trip_id", r, true)]; ok {
+	tripId := getString("trip_id", r, true)
+
+	if val, ok := trips[tripId]; ok {
 		trip = val
 	} else {
-		panic(errors.New("No trip with id " + getString("trip_id", r, true) + " found."))
+		panic(newFieldError(ErrUnknownReference, "trip_id", tripId, "No trip with id "+tripId+" found."))
 	}
 
-	if val, ok := stops[getString("stop_id", r, true)]; ok {
+	stopId := getString("stop_id", r, true)
+
+	if val, ok := stops[stopId]; ok {
 		a.Stop = val
 	} else {
-		panic(errors.New("No stop with id " + getString("stop_id", r, true) + " found."))
+		panic(newFieldError(ErrUnknownReference, "stop_id", stopId, "No stop with id "+stopId+" found."))
 	}
 
 	a.Arrival_time = getString("arrival_time", r, true)
@@ -186,16 +190,20 @@ func createTrip(r map[string]string, routes map[string]*gtfs.Route,
 	a := new(gtfs.Trip)
 	a.Id = getString("trip_id", r, true)
 
-	if val, ok := routes[getString("route_id", r, true)]; ok {
+	routeId := getString("route_id", r, true)
+
+	if val, ok := routes[routeId]; ok {
 		a.Route = val
 	} else {
-		panic(errors.New(fmt.Sprintf("No route with id %s found", getString("route_id", r, true))))
+		panic(newFieldError(ErrUnknownReference, "route_id", routeId, fmt.Sprintf("No route with id %s found", routeId)))
 	}
 
-	if val, ok := services[getString("service_id", r, true)]; ok {
+	serviceId := getString("service_id", r, true)
+
+	if val, ok := services[serviceId]; ok {
 		a.Service = val
 	} else {
-		panic(errors.New(fmt.Sprintf("No service with id %s found", getString("service_id", r, true))))
+		panic(newFieldError(ErrUnknownReference, "service_id", serviceId, fmt.Sprintf("No service with id %s found", serviceId)))
 	}
 
 	a.Headsign = getString("trip_headsign", r, false)
@@ -209,7 +217,7 @@ func createTrip(r map[string]string, routes map[string]*gtfs.Route,
 		if val, ok := shapes[shapeId]; ok {
 			a.Shape = val
 		} else {
-			panic(errors.New(fmt.Sprintf("No shape with id %s found", shapeId)))
+			panic(newFieldError(ErrUnknownReference, "shape_id", shapeId, fmt.Sprintf("No shape with id %s found", shapeId)))
 		}
 	}
 
@@ -264,7 +272,7 @@ func createFareRule(r map[string]string, fareattributes map[string]*gtfs.FareAtt
 	if val, ok := fareattributes[fareid]; ok {
 		fareattr = val
 	} else {
-		panic(errors.New(fmt.Sprintf("No fare attribute with id %s found", fareid)))
+		panic(newFieldError(ErrUnknownReference, "fare_id", fareid, fmt.Sprintf("No fare attribute with id %s found", fareid)))
 	}
 
 	// create fare attribute
@@ -277,7 +285,7 @@ func createFareRule(r map[string]string, fareattributes map[string]*gtfs.FareAtt
 		if val, ok := routes[route_id]; ok {
 			rule.Route = val
 		} else {
-			panic(errors.New(fmt.Sprintf("No route with id %s found", route_id)))
+			panic(newFieldError(ErrUnknownReference, "route_id", route_id, fmt.Sprintf("No route with id %s found", route_id)))
 		}
 	}
 
@@ -291,16 +299,20 @@ func createFareRule(r map[string]string, fareattributes map[string]*gtfs.FareAtt
 func createTransfer(r map[string]string, stops map[string]*gtfs.Stop) *gtfs.Transfer {
 	a := new(gtfs.Transfer)
 
-	if val, ok := stops[getString("from_stop_id", r, true)]; ok {
+	fromStopId := getString("from_stop_id", r, true)
+
+	if val, ok := stops[fromStopId]; ok {
 		a.From_stop = val
 	} else {
-		panic(errors.New("No stop with id " + getString("from_stop_id", r, true) + " found."))
+		panic(newFieldError(ErrUnknownReference, "from_stop_id", fromStopId, "No stop with id "+fromStopId+" found."))
 	}
 
-	if val, ok := stops[getString("to_stop_id", r, true)]; ok {
+	toStopId := getString("to_stop_id", r, true)
+
+	if val, ok := stops[toStopId]; ok {
 		a.To_stop = val
 	} else {
-		panic(errors.New("No stop with id " + getString("to_stop_id", r, true) + " found."))
+		panic(newFieldError(ErrUnknownReference, "to_stop_id", toStopId, "No stop with id "+toStopId+" found."))
 	}
 
 
@@ -313,7 +325,7 @@ func getString(name string, r map[string]string, req bool) string {
 	if val, ok := r[name]; ok {
 		return val
 	} else if req {
-		panic(errors.New(fmt.Sprintf("Expected required field '%s'", name)))
+		panic(newFieldError(ErrMissingField, name, "", fmt.Sprintf("Expected required field '%s'", name)))
 	}
 	return ""
 }
@@ -322,11 +334,11 @@ func getInt(name string, r map[string]string, req bool) int {
 	if val, ok := r[name]; ok && len(val) > 0 {
 		num, err := strconv.Atoi(val)
 		if err != nil {
-			panic(errors.New(fmt.Sprintf("Expected integer for field '%s', found '%s'", name, val)))
+			panic(newFieldError(ErrBadInteger, name, val, fmt.Sprintf("Expected integer for field '%s', found '%s'", name, val)))
 		}
 		return num
 	} else if req {
-		panic(errors.New(fmt.Sprintf("Expected required field '%s'", name)))
+		panic(newFieldError(ErrMissingField, name, "", fmt.Sprintf("Expected required field '%s'", name)))
 	}
 	return 0
 }
@@ -334,12 +346,15 @@ func getInt(name string, r map[string]string, req bool) int {
 func getPositiveInt(name string, r map[string]string, req bool) int {
 	if val, ok := r[name]; ok && len(val) > 0 {
 		num, err := strconv.Atoi(val)
-		if err != nil || num < 0 {
-			panic(errors.New(fmt.Sprintf("Expected positive integer for field '%s', found '%s'", name, val)))
+		if err != nil {
+			panic(newFieldError(ErrBadInteger, name, val, fmt.Sprintf("Expected positive integer for field '%s', found '%s'", name, val)))
+		}
+		if num < 0 {
+			panic(newFieldError(ErrOutOfRange, name, val, fmt.Sprintf("Expected positive integer for field '%s', found '%s'", name, val)))
 		}
 		return num
 	} else if req {
-		panic(errors.New(fmt.Sprintf("Expected required field '%s'", name)))
+		panic(newFieldError(ErrMissingField, name, "", fmt.Sprintf("Expected required field '%s'", name)))
 	}
 	return 0
 }
@@ -348,16 +363,16 @@ func getRangeInt(name string, r map[string]string, req bool, min int, max int) i
 	if val, ok := r[name]; ok && len(val) > 0 {
 		num, err := strconv.Atoi(val)
 		if err != nil {
-			panic(errors.New(fmt.Sprintf("Expected integer for field '%s', found '%s'", name, val)))
+			panic(newFieldError(ErrBadInteger, name, val, fmt.Sprintf("Expected integer for field '%s', found '%s'", name, val)))
 		}
 
 		if (num > max || num < min) {
-			panic(errors.New(fmt.Sprintf("Expected integer between %d and %d for field '%s', found %s", min, max, name, val)))
+			panic(newFieldError(ErrOutOfRange, name, val, fmt.Sprintf("Expected integer between %d and %d for field '%s', found %s", min, max, name, val)))
 		}
 
 		return num
 	} else if req {
-		panic(errors.New(fmt.Sprintf("Expected required field '%s'", name)))
+		panic(newFieldError(ErrMissingField, name, "", fmt.Sprintf("Expected required field '%s'", name)))
 	}
 	return 0
 }
@@ -366,11 +381,11 @@ func getRangeIntWithDefault(name string, r map[string]string, min int, max int,
 	if val, ok := r[name]; ok && len(val) > 0 {
 		num, err := strconv.Atoi(val)
 		if err != nil {
-			panic(errors.New(fmt.Sprintf("Expected integer for field '%s', found '%s'", name, val)))
+			panic(newFieldError(ErrBadInteger, name, val, fmt.Sprintf("Expected integer for field '%s', found '%s'", name, val)))
 		}
 
 		if (num > max || num < min) {
-			panic(errors.New(fmt.Sprintf("Expected integer between %d and %d for field '%s', found %s", min, max, name, val)))
+			panic(newFieldError(ErrOutOfRange, name, val, fmt.Sprintf("Expected integer between %d and %d for field '%s', found %s", min, max, name, val)))
 		}
 
 		return num
@@ -382,11 +397,11 @@ func getFloat(name string, r map[string]string, req bool) float32 {
 	if val, ok := r[name]; ok && len(val) > 0 {
 		num, err := strconv.ParseFloat(strings.TrimSpace(val), 32)
 		if err != nil {
-			panic(errors.New(fmt.Sprintf("Expected float for field '%s', found '%s'", name, val)))
+			panic(newFieldError(ErrBadFloat, name, val, fmt.Sprintf("Expected float for field '%s', found '%s'", name, val)))
 		}
 		return float32(num)
 	} else if req {
-		panic(errors.New(fmt.Sprintf("Expected required field '%s'", name)))
+		panic(newFieldError(ErrMissingField, name, "", fmt.Sprintf("Expected required field '%s'", name)))
 	}
 	return 0
 }
@@ -394,12 +409,15 @@ func getFloat(name string, r map[string]string, req bool) float32 {
 func getBool(name string, r map[string]string, req bool) bool {
 	if val, ok := r[name]; ok && len(val) > 0 {
 		num, err := strconv.Atoi(val)
-		if err != nil || (num != 0 && num != 1) {
-			panic(errors.New(fmt.Sprintf("Expected 1 or 0 for field '%s', found '%s'", name, val)))
+		if err != nil {
+			panic(newFieldError(ErrBadInteger, name, val, fmt.Sprintf("Expected 1 or 0 for field '%s', found '%s'", name, val)))
+		}
+		if num != 0 && num != 1 {
+			panic(newFieldError(ErrOutOfRange, name, val, fmt.Sprintf("Expected 1 or 0 for field '%s', found '%s'", name, val)))
 		}
 		return num == 1
 	} else if req {
-		panic(errors.New(fmt.Sprintf("Expected required field '%s'", name)))
+		panic(newFieldError(ErrMissingField, name, "", fmt.Sprintf("Expected required field '%s'", name)))
 	}
 	return false
 }
@@ -409,7 +427,7 @@ func getDate(name string, r map[string]string, req bool) gtfs.Date {
 	var ok bool
 	if str, ok = r[name]; !ok {
 		if req {
-			panic(errors.New(fmt.Sprintf("Expected required field '%s'", name)))
+			panic(newFieldError(ErrMissingField, name, "", fmt.Sprintf("Expected required field '%s'", name)))
 		} else {
 			return gtfs.Date{0, 0, 0}
 		}
@@ -431,7 +449,7 @@ func getDate(name string, r map[string]string, req bool) gtfs.Date {
 	}
 
 	if e != nil {
-		panic(errors.New(fmt.Sprintf("Expected YYYYMMDD date for field '%s', found '%s' (%s)", name, str, e.Error())))
+		panic(newFieldError(ErrBadDate, name, str, fmt.Sprintf("Expected YYYYMMDD date for field '%s', found '%s' (%s)", name, str, e.Error())))
 	} else {
 		return gtfs.Date{int8(day), int8(month), int16(year)}
 	}
@@ -0,0 +1,572 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"errors"
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// How to handle entities of different feeds with the same ID
+type CollisionStrategy int
+
+const (
+	// prefix colliding IDs with the prefix of their feed
+	CollisionPrefix CollisionStrategy = iota
+
+	// fail on colliding IDs
+	CollisionError
+
+	// keep a single entity if colliding entities are identical,
+	// prefix colliding IDs otherwise
+	CollisionDedupe
+)
+
+// Merges multiple feeds into a single one. Stops with the same ID and
+// coordinates are always merged into one stop.
+type Merger struct {
+	Collisions CollisionStrategy
+
+	// ID prefix for each feed, defaults to "<n>_" for the n-th feed
+	Prefixes []string
+}
+
+// Create a new Merger which prefixes colliding IDs
+func NewMerger() *Merger {
+	return &Merger{Collisions: CollisionPrefix}
+}
+
+// Merge feeds into a new feed, using the default Merger
+func Merge(feeds ...*Feed) (*Feed, error) {
+	return NewMerger().Merge(feeds...)
+}
+
+// The merge state of a single source feed
+type mergeSource struct {
+	n        int
+	feed     *Feed
+	agencies map[*gtfs.Agency]*gtfs.Agency
+	stops    map[*gtfs.Stop]*gtfs.Stop
+	stopIds  map[string]string
+	zones    map[string]string
+	routes   map[*gtfs.Route]*gtfs.Route
+	services map[*gtfs.Service]*gtfs.Service
+	shapes   map[*gtfs.Shape]*gtfs.Shape
+}
+
+// Merge feeds into a new feed. The source feeds are not modified.
+func (m *Merger) Merge(feeds ...*Feed) (*Feed, error) {
+	merged := NewFeed()
+	zones := make(map[string]bool)
+	transfers := make(map[[2]*gtfs.Stop]bool)
+	sources := make([]*mergeSource, 0, len(feeds))
+
+	for n, feed := range feeds {
+		src := &mergeSource{
+			n:        n,
+			feed:     feed,
+			agencies: make(map[*gtfs.Agency]*gtfs.Agency),
+			stops:    make(map[*gtfs.Stop]*gtfs.Stop),
+			stopIds:  make(map[string]string),
+			zones:    make(map[string]string),
+			routes:   make(map[*gtfs.Route]*gtfs.Route),
+			services: make(map[*gtfs.Service]*gtfs.Service),
+			shapes:   make(map[*gtfs.Shape]*gtfs.Shape),
+		}
+		sources = append(sources, src)
+
+		steps := []func(*Feed, *mergeSource) error{
+			m.mergeAgencies,
+			func(merged *Feed, src *mergeSource) error { return m.mergeZones(merged, zones, src) },
+			m.mergeStops,
+			m.mergeRoutes,
+			m.mergeServices,
+			m.mergeShapes,
+			m.mergeTrips,
+			m.mergeFareAttributes,
+		}
+
+		for _, step := range steps {
+			if err := step(merged, src); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, t := range feed.Transfers {
+			clone := *t
+			clone.From_stop = src.stops[t.From_stop]
+			clone.To_stop = src.stops[t.To_stop]
+
+			// transfers between merged stops may be contained twice
+			key := [2]*gtfs.Stop{clone.From_stop, clone.To_stop}
+			if !transfers[key] {
+				transfers[key] = true
+				merged.Transfers = append(merged.Transfers, &clone)
+			}
+		}
+	}
+
+	// a single agency without ID in the source feeds keeps having none
+	unnamed := len(merged.Agencies) == 1
+	for _, src := range sources {
+		for a := range src.agencies {
+			unnamed = unnamed && len(a.Id) == 0
+		}
+	}
+
+	if unnamed {
+		for id, a := range merged.Agencies {
+			delete(merged.Agencies, id)
+			a.Id = ""
+			merged.Agencies[""] = a
+		}
+	}
+
+	if fi := mergeFeedInfos(feeds); fi != nil {
+		merged.FeedInfos = append(merged.FeedInfos, fi)
+	}
+
+	return merged, nil
+}
+
+func (src *mergeSource) getZone(id string) string {
+	if newId, ok := src.zones[id]; ok {
+		return newId
+	}
+	return id
+}
+
+func (m *Merger) getPrefix(n int) string {
+	if n < len(m.Prefixes) {
+		return m.Prefixes[n]
+	}
+	return fmt.Sprintf("%d_", n+1)
+}
+
+// Get the ID under which an entity is added to the merged feed. If the
+// returned bool is true, the identical entity with this ID is reused.
+func (m *Merger) getId(kind string, id string, n int, exists func(string) bool, equal func(string) bool) (string, bool, error) {
+	if !exists(id) {
+		return id, false, nil
+	}
+
+	switch m.Collisions {
+	case CollisionError:
+		return "", false, errors.New(fmt.Sprintf("%s ID '%s' of feed %d collides with a previous feed", kind, id, n+1))
+	case CollisionDedupe:
+		if equal(id) {
+			return id, true, nil
+		}
+	}
+
+	for exists(id) {
+		id = m.getPrefix(n) + id
+	}
+
+	return id, false, nil
+}
+
+func getSortedIds(ids []string) []string {
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *Merger) mergeAgencies(merged *Feed, src *mergeSource) error {
+	ids := make([]string, 0, len(src.feed.Agencies))
+	for id := range src.feed.Agencies {
+		ids = append(ids, id)
+	}
+
+	for _, id := range getSortedIds(ids) {
+		a := src.feed.Agencies[id]
+		clone := *a
+
+		// agencies without ID need one once the merged feed has several
+		// agencies, it is removed again in Merge if not
+		if len(id) == 0 {
+			id = "agency"
+		}
+
+		newId, reuse, err := m.getId("Agency", id, src.n,
+			func(id string) bool { _, ok := merged.Agencies[id]; return ok },
+			func(id string) bool { c := clone; c.Id = id; return reflect.DeepEqual(&c, merged.Agencies[id]) })
+		if err != nil {
+			return err
+		}
+
+		if reuse {
+			src.agencies[a] = merged.Agencies[newId]
+			continue
+		}
+
+		clone.Id = newId
+		merged.Agencies[newId] = &clone
+		src.agencies[a] = &clone
+	}
+
+	return nil
+}
+
+// Map the zone IDs of a feed's stops and fare rules to the merged feed.
+// Zones of stops merged with a stop of a previous feed are shared with
+// that stop. Other zone IDs already used by a previous feed are
+// prefixed, as zones cannot be compared between feeds. When deduping,
+// equal zone IDs are assumed to denote the same zone.
+func (m *Merger) mergeZones(merged *Feed, zones map[string]bool, src *mergeSource) error {
+	stopIds := make([]string, 0, len(src.feed.Stops))
+	for id := range src.feed.Stops {
+		stopIds = append(stopIds, id)
+	}
+
+	own := make(map[string]bool)
+
+	for _, id := range getSortedIds(stopIds) {
+		s := src.feed.Stops[id]
+		if len(s.Zone_id) == 0 {
+			continue
+		}
+		own[s.Zone_id] = true
+
+		// see mergeStops
+		if prev, ok := merged.Stops[id]; ok && prev.Lat == s.Lat && prev.Lon == s.Lon && len(prev.Zone_id) > 0 {
+			if _, mapped := src.zones[s.Zone_id]; !mapped {
+				src.zones[s.Zone_id] = prev.Zone_id
+			}
+		}
+	}
+
+	for _, fa := range src.feed.FareAttributes {
+		for _, r := range fa.Rules {
+			for _, z := range []string{r.Origin_id, r.Destination_id, r.Contains_id} {
+				if len(z) > 0 {
+					own[z] = true
+				}
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(own))
+	for id := range own {
+		ids = append(ids, id)
+	}
+
+	for _, id := range getSortedIds(ids) {
+		if _, mapped := src.zones[id]; mapped {
+			continue
+		}
+
+		newId := id
+		if zones[id] && m.Collisions != CollisionDedupe {
+			if m.Collisions == CollisionError {
+				return errors.New(fmt.Sprintf("Zone ID '%s' of feed %d collides with a previous feed", id, src.n+1))
+			}
+			for zones[newId] {
+				newId = m.getPrefix(src.n) + newId
+			}
+		}
+		src.zones[id] = newId
+	}
+
+	for _, id := range src.zones {
+		zones[id] = true
+	}
+
+	return nil
+}
+
+func (m *Merger) mergeStops(merged *Feed, src *mergeSource) error {
+	ids := make([]string, 0, len(src.feed.Stops))
+	for id := range src.feed.Stops {
+		ids = append(ids, id)
+	}
+	ids = getSortedIds(ids)
+
+	// parent stations first, so their new IDs are known for the children
+	sort.SliceStable(ids, func(i, j int) bool {
+		return len(src.feed.Stops[ids[i]].Parent_station) == 0 && len(src.feed.Stops[ids[j]].Parent_station) > 0
+	})
+
+	for _, id := range ids {
+		s := src.feed.Stops[id]
+		clone := *s
+		clone.Zone_id = src.getZone(s.Zone_id)
+		if parentId, ok := src.stopIds[s.Parent_station]; ok {
+			clone.Parent_station = parentId
+		}
+
+		// stops with the same ID and coordinates are the same stop, see
+		// also mergeZones
+		if prev, ok := merged.Stops[id]; ok && prev.Lat == s.Lat && prev.Lon == s.Lon {
+			src.stops[s] = prev
+			src.stopIds[id] = id
+			continue
+		}
+
+		newId, reuse, err := m.getId("Stop", id, src.n,
+			func(id string) bool { _, ok := merged.Stops[id]; return ok },
+			func(id string) bool { c := clone; c.Id = id; return reflect.DeepEqual(&c, merged.Stops[id]) })
+		if err != nil {
+			return err
+		}
+
+		src.stopIds[id] = newId
+
+		if reuse {
+			src.stops[s] = merged.Stops[newId]
+			continue
+		}
+
+		clone.Id = newId
+		merged.Stops[newId] = &clone
+		src.stops[s] = &clone
+	}
+
+	return nil
+}
+
+func (m *Merger) mergeRoutes(merged *Feed, src *mergeSource) error {
+	ids := make([]string, 0, len(src.feed.Routes))
+	for id := range src.feed.Routes {
+		ids = append(ids, id)
+	}
+
+	for _, id := range getSortedIds(ids) {
+		r := src.feed.Routes[id]
+		clone := *r
+
+		// the agency has to be set explicitly once the merged feed
+		// has several agencies
+		if agency := src.feed.getRouteAgency(r); agency != nil {
+			clone.Agency = src.agencies[agency]
+		}
+
+		newId, reuse, err := m.getId("Route", id, src.n,
+			func(id string) bool { _, ok := merged.Routes[id]; return ok },
+			func(id string) bool { c := clone; c.Id = id; return reflect.DeepEqual(&c, merged.Routes[id]) })
+		if err != nil {
+			return err
+		}
+
+		if reuse {
+			src.routes[r] = merged.Routes[newId]
+			continue
+		}
+
+		clone.Id = newId
+		merged.Routes[newId] = &clone
+		src.routes[r] = &clone
+	}
+
+	return nil
+}
+
+func (m *Merger) mergeServices(merged *Feed, src *mergeSource) error {
+	ids := make([]string, 0, len(src.feed.Services))
+	for id := range src.feed.Services {
+		ids = append(ids, id)
+	}
+
+	for _, id := range getSortedIds(ids) {
+		s := src.feed.Services[id]
+		clone := *s
+		clone.Exceptions = make([]*gtfs.ServiceException, len(s.Exceptions))
+		for i, e := range s.Exceptions {
+			ecopy := *e
+			clone.Exceptions[i] = &ecopy
+		}
+
+		newId, reuse, err := m.getId("Service", id, src.n,
+			func(id string) bool { _, ok := merged.Services[id]; return ok },
+			func(id string) bool { c := clone; c.Id = id; return reflect.DeepEqual(&c, merged.Services[id]) })
+		if err != nil {
+			return err
+		}
+
+		if reuse {
+			src.services[s] = merged.Services[newId]
+			continue
+		}
+
+		clone.Id = newId
+		merged.Services[newId] = &clone
+		src.services[s] = &clone
+	}
+
+	return nil
+}
+
+func (m *Merger) mergeShapes(merged *Feed, src *mergeSource) error {
+	ids := make([]string, 0, len(src.feed.Shapes))
+	for id := range src.feed.Shapes {
+		ids = append(ids, id)
+	}
+
+	for _, id := range getSortedIds(ids) {
+		s := src.feed.Shapes[id]
+		clone := *s
+		clone.Points = make(gtfs.ShapePoints, len(s.Points))
+		for i, p := range s.Points {
+			pcopy := *p
+			clone.Points[i] = &pcopy
+		}
+
+		newId, reuse, err := m.getId("Shape", id, src.n,
+			func(id string) bool { _, ok := merged.Shapes[id]; return ok },
+			func(id string) bool { c := clone; c.Id = id; return reflect.DeepEqual(&c, merged.Shapes[id]) })
+		if err != nil {
+			return err
+		}
+
+		if reuse {
+			src.shapes[s] = merged.Shapes[newId]
+			continue
+		}
+
+		clone.Id = newId
+		merged.Shapes[newId] = &clone
+		src.shapes[s] = &clone
+	}
+
+	return nil
+}
+
+func (m *Merger) mergeTrips(merged *Feed, src *mergeSource) error {
+	ids := make([]string, 0, len(src.feed.Trips))
+	for id := range src.feed.Trips {
+		ids = append(ids, id)
+	}
+
+	for _, id := range getSortedIds(ids) {
+		t := src.feed.Trips[id]
+		clone := *t
+		clone.Route = src.routes[t.Route]
+		clone.Service = src.services[t.Service]
+		if t.Shape != nil {
+			clone.Shape = src.shapes[t.Shape]
+		}
+
+		clone.StopTimes = make(gtfs.StopTimes, len(t.StopTimes))
+		for i, st := range t.StopTimes {
+			stcopy := *st
+			stcopy.Stop = src.stops[st.Stop]
+			clone.StopTimes[i] = &stcopy
+		}
+
+		clone.Frequencies = make([]*gtfs.Frequency, len(t.Frequencies))
+		for i, f := range t.Frequencies {
+			fcopy := *f
+			clone.Frequencies[i] = &fcopy
+		}
+
+		newId, reuse, err := m.getId("Trip", id, src.n,
+			func(id string) bool { _, ok := merged.Trips[id]; return ok },
+			func(id string) bool { c := clone; c.Id = id; return reflect.DeepEqual(&c, merged.Trips[id]) })
+		if err != nil {
+			return err
+		}
+
+		if reuse {
+			continue
+		}
+
+		clone.Id = newId
+		merged.Trips[newId] = &clone
+	}
+
+	return nil
+}
+
+func (m *Merger) mergeFareAttributes(merged *Feed, src *mergeSource) error {
+	ids := make([]string, 0, len(src.feed.FareAttributes))
+	for id := range src.feed.FareAttributes {
+		ids = append(ids, id)
+	}
+
+	for _, id := range getSortedIds(ids) {
+		fa := src.feed.FareAttributes[id]
+		clone := *fa
+		clone.Rules = make([]*gtfs.FareAttributeRule, len(fa.Rules))
+		for i, r := range fa.Rules {
+			rcopy := *r
+			if r.Route != nil {
+				rcopy.Route = src.routes[r.Route]
+			}
+			rcopy.Origin_id = src.getZone(r.Origin_id)
+			rcopy.Destination_id = src.getZone(r.Destination_id)
+			rcopy.Contains_id = src.getZone(r.Contains_id)
+			clone.Rules[i] = &rcopy
+		}
+
+		newId, reuse, err := m.getId("Fare", id, src.n,
+			func(id string) bool { _, ok := merged.FareAttributes[id]; return ok },
+			func(id string) bool { c := clone; c.Id = id; return reflect.DeepEqual(&c, merged.FareAttributes[id]) })
+		if err != nil {
+			return err
+		}
+
+		if reuse {
+			continue
+		}
+
+		clone.Id = newId
+		merged.FareAttributes[newId] = &clone
+	}
+
+	return nil
+}
+
+// Combine the feed infos of all feeds into one, covering the validity
+// windows of all feeds. Returns nil if no feed has a feed info.
+func mergeFeedInfos(feeds []*Feed) *gtfs.FeedInfo {
+	var ret *gtfs.FeedInfo
+	names := make([]string, 0)
+	versions := make([]string, 0)
+
+	for _, feed := range feeds {
+		for _, fi := range feed.FeedInfos {
+			if ret == nil {
+				clone := *fi
+				ret = &clone
+			}
+
+			if !containsString(names, fi.Publisher_name) {
+				names = append(names, fi.Publisher_name)
+			}
+			if len(fi.Version) > 0 && !containsString(versions, fi.Version) {
+				versions = append(versions, fi.Version)
+			}
+
+			if len(ret.Publisher_url) == 0 {
+				ret.Publisher_url = fi.Publisher_url
+			}
+			if fi.Lang != ret.Lang {
+				ret.Lang = "mul"
+			}
+			if fi.Phone != ret.Phone {
+				ret.Phone = ""
+			}
+
+			if !fi.Start_date.IsEmpty() && (ret.Start_date.IsEmpty() || fi.Start_date.Before(ret.Start_date)) {
+				ret.Start_date = fi.Start_date
+			}
+			if !fi.End_date.IsEmpty() && (ret.End_date.IsEmpty() || fi.End_date.After(ret.End_date)) {
+				ret.End_date = fi.End_date
+			}
+		}
+	}
+
+	if ret != nil {
+		ret.Publisher_name = strings.Join(names, ", ")
+		ret.Version = strings.Join(versions, ";")
+	}
+
+	return ret
+}
@@ -0,0 +1,122 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"testing"
+)
+
+func TestMergePrefix(t *testing.T) {
+	merged, err := Merge(newTestFeed(), newTestFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkIds(t, "trips", getIds(merged.Trips), "2_T1", "2_T2", "T1", "T2")
+	checkIds(t, "agencies", getIds(merged.Agencies), "2_A1", "2_A2", "2_AX", "A1", "A2", "AX")
+
+	// stops with the same ID and coordinates are merged
+	checkIds(t, "stops", getIds(merged.Stops), "S1", "S2", "S3", "S4", "SX")
+
+	trip := merged.Trips["2_T1"]
+	if trip.Route != merged.Routes["2_R1"] || trip.Service != merged.Services["2_WEEK"] || trip.Shape != merged.Shapes["2_SH1"] {
+		t.Errorf("got trip 2_T1 on route %s, service %s and shape %s", trip.Route.Id, trip.Service.Id, trip.Shape.Id)
+	}
+	if trip.Route.Agency != merged.Agencies["2_A1"] {
+		t.Errorf("got agency %s for route 2_R1, want 2_A1", trip.Route.Agency.Id)
+	}
+	if trip.StopTimes[0].Stop != merged.Trips["T1"].StopTimes[0].Stop {
+		t.Errorf("got different stops for T1 and 2_T1")
+	}
+
+	// zones of merged stops are shared, zones only used by fare rules
+	// are prefixed
+	if z := merged.FareAttributes["2_F2"].Rules[0].Origin_id; z != "z4" {
+		t.Errorf("got zone %s, want z4", z)
+	}
+	if z := merged.FareAttributes["2_FX"].Rules[0].Origin_id; z != "2_zx" {
+		t.Errorf("got zone %s, want 2_zx", z)
+	}
+
+	if len(merged.Transfers) != 1 {
+		t.Errorf("got %d transfers, want 1", len(merged.Transfers))
+	}
+}
+
+func TestMergeError(t *testing.T) {
+	m := NewMerger()
+	m.Collisions = CollisionError
+
+	if _, err := m.Merge(newTestFeed(), newTestFeed()); err == nil {
+		t.Error("got no error for colliding IDs")
+	}
+}
+
+func TestMergeDedupe(t *testing.T) {
+	m := NewMerger()
+	m.Collisions = CollisionDedupe
+
+	b := newTestFeed()
+	b.Trips["T2"].StopTimes[1].Arrival_time = "09:12:00"
+
+	merged, err := m.Merge(newTestFeed(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the changed trip is contained twice
+	checkIds(t, "trips", getIds(merged.Trips), "2_T2", "T1", "T2")
+	checkIds(t, "routes", getIds(merged.Routes), "R1", "R2", "RX")
+	checkIds(t, "agencies", getIds(merged.Agencies), "A1", "A2", "AX")
+	checkIds(t, "fare attributes", getIds(merged.FareAttributes), "F1", "F2", "FX")
+
+	if merged.Trips["2_T2"].Route != merged.Routes["R2"] {
+		t.Errorf("got route %s for 2_T2, want R2", merged.Trips["2_T2"].Route.Id)
+	}
+}
+
+func TestMergeUnnamedAgencies(t *testing.T) {
+	getFeed := func() *Feed {
+		feed := newTestFeed()
+		a := feed.Agencies["A1"]
+		a.Id = ""
+		feed.Agencies = map[string]*gtfs.Agency{"": a}
+		for _, r := range feed.Routes {
+			r.Agency = nil
+		}
+		return feed
+	}
+
+	merged, err := Merge(getFeed(), getFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(merged.Agencies) != 2 {
+		t.Fatalf("got %d agencies, want 2", len(merged.Agencies))
+	}
+	for id, a := range merged.Agencies {
+		if len(id) == 0 || a.Id != id {
+			t.Errorf("got agency with ID '%s' under '%s', want a non-empty ID", a.Id, id)
+		}
+	}
+	for id, r := range merged.Routes {
+		if r.Agency == nil || merged.Agencies[r.Agency.Id] != r.Agency {
+			t.Errorf("route %s has no agency of the merged feed", id)
+		}
+	}
+
+	// a single agency keeps having no ID
+	m := NewMerger()
+	m.Collisions = CollisionDedupe
+	merged, err = m.Merge(getFeed(), getFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIds(t, "agencies", getIds(merged.Agencies), "")
+}
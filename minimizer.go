@@ -0,0 +1,138 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+	"strings"
+)
+
+// Removes unused entities from a feed and merges duplicates
+type Minimizer struct {
+	// merge shapes with identical points
+	MergeShapes bool
+
+	// merge services active on the same dates
+	MergeServices bool
+}
+
+// Create a new Minimizer which merges duplicate shapes and services
+func NewMinimizer() *Minimizer {
+	return &Minimizer{MergeShapes: true, MergeServices: true}
+}
+
+// Minimize the feed using the default Minimizer
+func (feed *Feed) Minimize() *Removed {
+	return NewMinimizer().Apply(feed)
+}
+
+func (m *Minimizer) Apply(feed *Feed) *Removed {
+	removed := &Removed{}
+
+	if m.MergeShapes {
+		feed.mergeDuplicateShapes()
+	}
+
+	if m.MergeServices {
+		feed.mergeEquivalentServices()
+	}
+
+	feed.removeOrphans(feed.getAll(), removed)
+
+	return removed
+}
+
+// Let all trips use the shape with the lowest ID among shapes with
+// identical points
+func (feed *Feed) mergeDuplicateShapes() {
+	ids := make([]string, 0, len(feed.Shapes))
+	for id := range feed.Shapes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	first := make(map[string]*gtfs.Shape)
+	replace := make(map[*gtfs.Shape]*gtfs.Shape)
+
+	for _, id := range ids {
+		shape := feed.Shapes[id]
+		key := getShapeKey(shape)
+
+		if f, ok := first[key]; ok {
+			replace[shape] = f
+		} else {
+			first[key] = shape
+		}
+	}
+
+	for _, trip := range feed.Trips {
+		if r, ok := replace[trip.Shape]; ok {
+			trip.Shape = r
+		}
+	}
+}
+
+func getShapeKey(shape *gtfs.Shape) string {
+	var b strings.Builder
+	for _, p := range shape.Points {
+		fmt.Fprintf(&b, "%v,%v,%d,%v;", p.Lat, p.Lon, p.Sequence, p.Dist_traveled)
+	}
+	return b.String()
+}
+
+// Let all trips use the service with the lowest ID among services
+// active on the same dates. Services which are never active are left
+// unmerged.
+func (feed *Feed) mergeEquivalentServices() {
+	ids := make([]string, 0, len(feed.Services))
+	for id := range feed.Services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	first := make(map[string]*gtfs.Service)
+	replace := make(map[*gtfs.Service]*gtfs.Service)
+
+	for _, id := range ids {
+		service := feed.Services[id]
+		key := getServiceKey(service)
+		if len(key) == 0 {
+			continue
+		}
+
+		if f, ok := first[key]; ok {
+			replace[service] = f
+		} else {
+			first[key] = service
+		}
+	}
+
+	for _, trip := range feed.Trips {
+		if r, ok := replace[trip.Service]; ok {
+			trip.Service = r
+		}
+	}
+}
+
+func getServiceKey(service *gtfs.Service) string {
+	var b strings.Builder
+
+	first, last := service.GetFirstActiveDate(), service.GetLastActiveDate()
+	if first.IsEmpty() {
+		return ""
+	}
+
+	for d := first; !d.After(last); d = d.AddDays(1) {
+		if service.IsActiveOn(d) {
+			b.WriteString(d.String())
+		}
+	}
+
+	return b.String()
+}
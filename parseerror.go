@@ -7,15 +7,121 @@
 package gtfsparser
 
 import (
+	"encoding/csv"
+	"errors"
 	"fmt"
 )
 
+// A stable code describing the kind of a ParseError. ErrorCodes can be
+// used as targets for errors.Is:
+//
+//	if errors.Is(err, gtfsparser.ErrUnknownReference) { ... }
+type ErrorCode int
+
+const (
+	ErrUnknown ErrorCode = iota
+	ErrMissingField
+	ErrBadInteger
+	ErrBadFloat
+	ErrBadDate
+	ErrOutOfRange
+	ErrUnknownReference
+	ErrBadCsv
+)
+
+// Get a string representation of an ErrorCode
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrMissingField:
+		return "missing_required_field"
+	case ErrBadInteger:
+		return "bad_integer"
+	case ErrBadFloat:
+		return "bad_float"
+	case ErrBadDate:
+		return "bad_date"
+	case ErrOutOfRange:
+		return "out_of_range"
+	case ErrUnknownReference:
+		return "unknown_reference"
+	case ErrBadCsv:
+		return "bad_csv"
+	}
+	return "unknown"
+}
+
+func (c ErrorCode) Error() string {
+	return c.String()
+}
+
 type ParseError struct {
-	filename string
-	line     int
-	msg      string
+	Filename string
+	Line     int
+	Column   string // name of the offending field, if known
+	Value    string // raw value of the offending field, if known
+	EntityId string // ID of the entity the record belongs to, if known
+	Code     ErrorCode
+	Msg      string
+	Err      error // underlying error, if any
 }
 
 func (e ParseError) Error() string {
-	return fmt.Sprintf("%s:%d - %s", e.filename, e.line, e.msg)
+	return fmt.Sprintf("%s:%d - %s", e.Filename, e.Line, e.Msg)
+}
+
+func (e ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Check whether this error has the ErrorCode target
+func (e ParseError) Is(target error) bool {
+	if code, ok := target.(ErrorCode); ok {
+		return e.Code == code
+	}
+	return false
+}
+
+// Get a Finding describing this error, to include it in a Report
+func (e ParseError) GetFinding() Finding {
+	return Finding{
+		Code:     e.Code.String(),
+		Severity: SeverityError,
+		Filename: e.Filename,
+		Line:     e.Line,
+		EntityId: e.EntityId,
+		Field:    e.Column,
+		Msg:      e.Msg,
+	}
+}
+
+// Create a ParseError for a single field. File and line information
+// are added once the error is recovered in the file parser.
+func newFieldError(code ErrorCode, column string, value string, msg string) ParseError {
+	return ParseError{Code: code, Column: column, Value: value, Msg: msg}
+}
+
+// Create a ParseError from a value recovered during the parsing of a file
+func newParseError(r interface{}, filename string, line int, entityId string) ParseError {
+	var e ParseError
+
+	switch v := r.(type) {
+	case ParseError:
+		e = v
+		e.EntityId = entityId
+	case error:
+		var csvErr *csv.ParseError
+		if errors.As(v, &csvErr) {
+			// the record could not be read, entityId belongs to the previous one
+			e = ParseError{Code: ErrBadCsv, Msg: v.Error(), Err: v}
+		} else {
+			e = ParseError{Code: ErrUnknown, Msg: v.Error(), Err: v}
+		}
+	default:
+		e = ParseError{Code: ErrUnknown, Msg: fmt.Sprint(v)}
+	}
+
+	e.Filename = filename
+	e.Line = line
+
+	return e
 }
@@ -0,0 +1,120 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"math"
+	"sort"
+	"strings"
+)
+
+// sort key for trips without a valid departure
+const infiniteTime = math.MaxInt32
+
+// A journey pattern: trips of the same route and direction serving
+// the same sequence of stops
+type Pattern struct {
+	Id           string
+	Route        *gtfs.Route
+	Direction_id int
+	Stops        []*gtfs.Stop
+	Trips        []*gtfs.Trip // sorted by departure at the first stop
+}
+
+// Get all journey patterns of the feed, sorted by route ID and direction.
+// Patterns of the same route and direction are sorted by descending
+// number of trips and are numbered in this order.
+func (feed *Feed) Patterns() []*Pattern {
+	trips := make([]*gtfs.Trip, 0, len(feed.Trips))
+	for _, trip := range feed.Trips {
+		trips = append(trips, trip)
+	}
+	return getPatterns(trips)
+}
+
+// Get the journey patterns of a single route, sorted by direction
+func (feed *Feed) PatternsForRoute(route *gtfs.Route) []*Pattern {
+	return getPatterns(feed.TripsForRoute(route))
+}
+
+func getPatterns(trips []*gtfs.Trip) []*Pattern {
+	byKey := make(map[string]*Pattern)
+
+	for _, trip := range trips {
+		if len(trip.StopTimes) == 0 {
+			continue
+		}
+
+		stops := make([]*gtfs.Stop, len(trip.StopTimes))
+		ids := make([]string, len(trip.StopTimes))
+		for i, st := range trip.StopTimes {
+			stops[i] = st.Stop
+			ids[i] = st.Stop.Id
+		}
+
+		key := fmt.Sprintf("%s\x00%d\x00%s", trip.Route.Id, trip.Direction_id, strings.Join(ids, "\x00"))
+
+		if p, ok := byKey[key]; ok {
+			p.Trips = append(p.Trips, trip)
+		} else {
+			byKey[key] = &Pattern{Route: trip.Route, Direction_id: trip.Direction_id, Stops: stops, Trips: []*gtfs.Trip{trip}}
+		}
+	}
+
+	ret := make([]*Pattern, 0, len(byKey))
+	for _, p := range byKey {
+		sortTripsByDeparture(p.Trips)
+		ret = append(ret, p)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.Route.Id != b.Route.Id {
+			return a.Route.Id < b.Route.Id
+		}
+		if a.Direction_id != b.Direction_id {
+			return a.Direction_id < b.Direction_id
+		}
+		if len(a.Trips) != len(b.Trips) {
+			return len(a.Trips) > len(b.Trips)
+		}
+		return a.Trips[0].Id < b.Trips[0].Id
+	})
+
+	n := 0
+	for i, p := range ret {
+		if i > 0 && (p.Route != ret[i-1].Route || p.Direction_id != ret[i-1].Direction_id) {
+			n = 0
+		}
+		n++
+		p.Id = fmt.Sprintf("%s_%d_%d", p.Route.Id, p.Direction_id, n)
+	}
+
+	return ret
+}
+
+// Sort trips by their departure at the first stop. Trips without a
+// valid first departure come last.
+func sortTripsByDeparture(trips []*gtfs.Trip) {
+	deps := make(map[*gtfs.Trip]int, len(trips))
+	for _, t := range trips {
+		dep, err := getFirstDeparture(t)
+		if err != nil {
+			dep = infiniteTime
+		}
+		deps[t] = dep
+	}
+
+	sort.Slice(trips, func(i, j int) bool {
+		if deps[trips[i]] != deps[trips[j]] {
+			return deps[trips[i]] < deps[trips[j]]
+		}
+		return trips[i].Id < trips[j].Id
+	})
+}
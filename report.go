@@ -0,0 +1,142 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"sort"
+)
+
+// All findings of a single rule code and severity
+type ReportGroup struct {
+	Code     string         `json:"code"`
+	Severity string         `json:"severity"`
+	Count    int            `json:"count"`
+	Samples  []ReportSample `json:"samples"`
+}
+
+// A single finding as written to a report
+type ReportSample struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line,omitempty"`
+	EntityId string `json:"entity_id,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Msg      string `json:"message"`
+}
+
+// A summary of validation findings
+type Report struct {
+	Counts map[string]int `json:"counts"`
+	Groups []ReportGroup  `json:"notices"`
+}
+
+// Create a report from findings, grouped by rule code and severity.
+// At most maxSamples findings are kept per group, all are kept if
+// maxSamples is negative.
+func NewReport(findings []Finding, maxSamples int) *Report {
+	type key struct {
+		code     string
+		severity Severity
+	}
+
+	groups := make(map[key]*ReportGroup)
+	keys := make([]key, 0)
+
+	report := &Report{
+		Counts: map[string]int{
+			SeverityError.String():   0,
+			SeverityWarning.String(): 0,
+			SeverityInfo.String():    0,
+		},
+	}
+
+	for _, f := range findings {
+		k := key{f.Code, f.Severity}
+		g, ok := groups[k]
+		if !ok {
+			g = &ReportGroup{Code: f.Code, Severity: f.Severity.String(), Samples: make([]ReportSample, 0)}
+			groups[k] = g
+			keys = append(keys, k)
+		}
+
+		g.Count++
+		report.Counts[f.Severity.String()]++
+
+		if maxSamples < 0 || len(g.Samples) < maxSamples {
+			g.Samples = append(g.Samples, ReportSample{
+				Filename: f.Filename,
+				Line:     f.Line,
+				EntityId: f.EntityId,
+				Field:    f.Field,
+				Msg:      f.Msg,
+			})
+		}
+	}
+
+	// most severe first, then by code
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].severity != keys[j].severity {
+			return keys[i].severity > keys[j].severity
+		}
+		return keys[i].code < keys[j].code
+	})
+
+	report.Groups = make([]ReportGroup, 0, len(keys))
+	for _, k := range keys {
+		report.Groups = append(report.Groups, *groups[k])
+	}
+
+	return report
+}
+
+// Write the report as JSON
+func (r *Report) WriteJson(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Write the report as a standalone HTML page
+func (r *Report) WriteHtml(w io.Writer) error {
+	return htmlReportTemplate.Execute(w, r)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>GTFS validation report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #eee; }
+.error { color: #b00; }
+.warning { color: #b60; }
+.info { color: #06b; }
+</style>
+</head>
+<body>
+<h1>GTFS validation report</h1>
+<p>
+<span class="error">{{index .Counts "error"}} errors</span>,
+<span class="warning">{{index .Counts "warning"}} warnings</span>,
+<span class="info">{{index .Counts "info"}} infos</span>
+</p>
+{{range .Groups}}
+<h2 class="{{.Severity}}">{{.Code}} ({{.Severity}}, {{.Count}})</h2>
+<table>
+<tr><th>File</th><th>Line</th><th>Entity</th><th>Field</th><th>Message</th></tr>
+{{range .Samples}}<tr><td>{{.Filename}}</td><td>{{if .Line}}{{.Line}}{{end}}</td><td>{{.EntityId}}</td><td>{{.Field}}</td><td>{{.Msg}}</td></tr>
+{{end}}</table>
+{{if gt .Count (len .Samples)}}<p>{{len .Samples}} of {{.Count}} shown.</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,28 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package routing
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+)
+
+// A single leg of a journey, either on a trip or walking. Times are
+// given in seconds since the start of the router's service day.
+type Leg struct {
+	From      *gtfs.Stop // nil if walking from the origin coordinate
+	To        *gtfs.Stop // nil if walking to the destination coordinate
+	Departure int
+	Arrival   int
+	Trip      *gtfs.Trip // nil for walking legs
+}
+
+type Journey struct {
+	Legs      []Leg
+	Departure int
+	Arrival   int
+	Transfers int
+}
@@ -0,0 +1,362 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package routing
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"math"
+	"sort"
+)
+
+// special values for walkFrom
+const (
+	byTrip   = -1
+	byOrigin = -2
+)
+
+// A stop reachable by walking, together with the walking time in seconds
+type Access struct {
+	Stop     *gtfs.Stop
+	Duration int
+}
+
+// The labels of a single RAPTOR round
+type round struct {
+	// arrival by trip
+	tripArr   []int
+	trip      []*tripTimes
+	boardIdx  []int
+	alightIdx []int
+
+	// best arrival, by trip, by walking or at the origin
+	arr      []int
+	walkFrom []int
+}
+
+// The result of a RAPTOR run
+type search struct {
+	r         *Router
+	rounds    []*round
+	best      []int
+	bestRound []int
+	access    map[int]int
+
+	// arrivals after this time are ignored
+	limit int
+}
+
+func (r *Router) newRound() *round {
+	n := len(r.stops)
+	rd := &round{
+		tripArr:   make([]int, n),
+		trip:      make([]*tripTimes, n),
+		boardIdx:  make([]int, n),
+		alightIdx: make([]int, n),
+		arr:       make([]int, n),
+		walkFrom:  make([]int, n),
+	}
+	for i := 0; i < n; i++ {
+		rd.tripArr[i] = infinity
+		rd.arr[i] = infinity
+	}
+	return rd
+}
+
+// Run RAPTOR from the given origins, departing at dep. Arrivals
+// after limit are not considered.
+func (r *Router) run(origins []Access, dep int, limit int) *search {
+	s := &search{
+		r:         r,
+		best:      make([]int, len(r.stops)),
+		bestRound: make([]int, len(r.stops)),
+		access:    make(map[int]int),
+		limit:     limit,
+	}
+	for i := range s.best {
+		s.best[i] = infinity
+	}
+
+	rd := r.newRound()
+	marked := make(map[int]bool)
+
+	for _, o := range origins {
+		p, ok := r.stopIdx[o.Stop]
+		if !ok || dep+o.Duration >= rd.arr[p] || dep+o.Duration > limit {
+			continue
+		}
+		rd.arr[p] = dep + o.Duration
+		rd.walkFrom[p] = byOrigin
+		s.best[p] = rd.arr[p]
+		s.access[p] = o.Duration
+		marked[p] = true
+	}
+
+	s.relaxFootpaths(rd, marked, 0)
+	s.rounds = append(s.rounds, rd)
+
+	for k := 1; k <= r.opts.MaxTransfers+1 && len(marked) > 0; k++ {
+		prev := rd
+		rd = r.newRound()
+		marked = s.scanPatterns(prev, rd, marked, k)
+		s.relaxFootpaths(rd, marked, k)
+		s.rounds = append(s.rounds, rd)
+	}
+
+	return s
+}
+
+// Walk from all stops marked in this round. Walks are not chained.
+func (s *search) relaxFootpaths(rd *round, marked map[int]bool, k int) {
+	from := make([]int, 0, len(marked))
+	for p := range marked {
+		from = append(from, p)
+	}
+	sort.Ints(from)
+
+	for _, p := range from {
+		if rd.walkFrom[p] >= 0 {
+			continue
+		}
+		for _, fp := range s.r.footpaths[p] {
+			t := rd.arr[p] + fp.dur
+			if t < s.best[fp.to] && t <= s.limit {
+				rd.arr[fp.to] = t
+				rd.walkFrom[fp.to] = p
+				s.best[fp.to] = t
+				s.bestRound[fp.to] = k
+				marked[fp.to] = true
+			}
+		}
+	}
+}
+
+// Get the earliest time a vehicle can be boarded at stop p after
+// having arrived there in round rd
+func (s *search) readyTime(rd *round, p int) int {
+	if rd.arr[p] == infinity {
+		return infinity
+	}
+	if rd.walkFrom[p] == byTrip {
+		if s.r.changeTimes[p] < 0 {
+			return infinity
+		}
+		return rd.arr[p] + s.r.changeTimes[p]
+	}
+	return rd.arr[p]
+}
+
+func (s *search) scanPatterns(prev *round, rd *round, marked map[int]bool, k int) map[int]bool {
+	// the first marked stop of each pattern
+	first := make(map[int]int)
+	for p := range marked {
+		for _, ps := range s.r.stopPatterns[p] {
+			if i, ok := first[ps.pattern]; !ok || ps.idx < i {
+				first[ps.pattern] = ps.idx
+			}
+		}
+	}
+
+	pids := make([]int, 0, len(first))
+	for pi := range first {
+		pids = append(pids, pi)
+	}
+	sort.Ints(pids)
+
+	newMarked := make(map[int]bool)
+
+	for _, pi := range pids {
+		pat := s.r.patterns[pi]
+		cur := -1
+		boardIdx := 0
+
+		for i := first[pi]; i < len(pat.stops); i++ {
+			p := pat.stops[i]
+
+			if cur >= 0 {
+				tt := pat.trips[cur]
+				if tt.dropoff[i] && tt.arr[i] < s.best[p] && tt.arr[i] <= s.limit {
+					rd.tripArr[p] = tt.arr[i]
+					rd.trip[p] = tt
+					rd.boardIdx[p] = boardIdx
+					rd.alightIdx[p] = i
+					rd.arr[p] = tt.arr[i]
+					rd.walkFrom[p] = byTrip
+					s.best[p] = tt.arr[i]
+					s.bestRound[p] = k
+					newMarked[p] = true
+				}
+			}
+
+			// check whether an earlier trip can be caught here
+			ready := s.readyTime(prev, p)
+			if ready == infinity {
+				continue
+			}
+
+			j := sort.Search(len(pat.trips), func(j int) bool { return pat.trips[j].dep[i] >= ready })
+			for ; j < len(pat.trips) && (cur < 0 || j < cur); j++ {
+				if pat.trips[j].pickup[i] {
+					cur = j
+					boardIdx = i
+					break
+				}
+			}
+		}
+	}
+
+	return newMarked
+}
+
+// Get the earliest arrival at any of the egress stops in round k,
+// including the egress walk
+func (s *search) egressArrival(k int, egress []Access) (int, int) {
+	best, bestStop := infinity, -1
+	for _, e := range egress {
+		p, ok := s.r.stopIdx[e.Stop]
+		if !ok || s.rounds[k].arr[p] == infinity {
+			continue
+		}
+		if t := s.rounds[k].arr[p] + e.Duration; t < best {
+			best, bestStop = t, p
+		}
+	}
+	return best, bestStop
+}
+
+// Get the Pareto set of journeys regarding arrival time and number
+// of transfers, sorted by number of transfers
+func (s *search) journeys(egress []Access) []*Journey {
+	ret := make([]*Journey, 0)
+	best := infinity
+
+	for k := range s.rounds {
+		arr, p := s.egressArrival(k, egress)
+		if arr >= best {
+			continue
+		}
+		best = arr
+
+		j := s.reconstruct(k, p)
+		for _, e := range egress {
+			if e.Stop == s.r.stops[p] && e.Duration > 0 {
+				j.Legs = append(j.Legs, Leg{From: e.Stop, Departure: j.Arrival, Arrival: j.Arrival + e.Duration})
+				j.Arrival += e.Duration
+				break
+			}
+		}
+		ret = append(ret, j)
+	}
+
+	return ret
+}
+
+// Get the journey to stop p as found in round k
+func (s *search) reconstruct(k int, p int) *Journey {
+	legs := make([]Leg, 0)
+	mustTrip := false
+
+	for {
+		rd := s.rounds[k]
+
+		if !mustTrip && rd.walkFrom[p] >= 0 {
+			from := rd.walkFrom[p]
+			legs = append(legs, Leg{
+				From:      s.r.stops[from],
+				To:        s.r.stops[p],
+				Departure: rd.arr[p] - s.walkDuration(from, p),
+				Arrival:   rd.arr[p],
+			})
+			p = from
+			mustTrip = k > 0
+			continue
+		}
+
+		if !mustTrip && rd.walkFrom[p] == byOrigin {
+			if d := s.access[p]; d > 0 {
+				legs = append(legs, Leg{To: s.r.stops[p], Departure: rd.arr[p] - d, Arrival: rd.arr[p]})
+			}
+			break
+		}
+
+		tt := rd.trip[p]
+		board := s.r.stopIdx[tt.trip.StopTimes[rd.boardIdx[p]].Stop]
+		legs = append(legs, Leg{
+			From:      s.r.stops[board],
+			To:        s.r.stops[p],
+			Departure: tt.dep[rd.boardIdx[p]],
+			Arrival:   tt.arr[rd.alightIdx[p]],
+			Trip:      tt.trip,
+		})
+		p = board
+		mustTrip = false
+		k--
+	}
+
+	// legs were collected backwards
+	for i, j := 0, len(legs)-1; i < j; i, j = i+1, j-1 {
+		legs[i], legs[j] = legs[j], legs[i]
+	}
+
+	j := &Journey{Legs: legs}
+	if len(legs) > 0 {
+		j.Departure = legs[0].Departure
+		j.Arrival = legs[len(legs)-1].Arrival
+	}
+	for _, l := range legs {
+		if l.Trip != nil {
+			j.Transfers++
+		}
+	}
+	if j.Transfers > 0 {
+		j.Transfers--
+	}
+
+	return j
+}
+
+func (s *search) walkDuration(from int, to int) int {
+	for _, fp := range s.r.footpaths[from] {
+		if fp.to == to {
+			return fp.dur
+		}
+	}
+	return 0
+}
+
+// Get the journey from stop from to stop to departing not before dep
+// with the earliest arrival, or nil if there is none. Times are given
+// in seconds since the start of the router's service day.
+func (r *Router) EarliestArrival(from *gtfs.Stop, to *gtfs.Stop, dep int) *Journey {
+	js := r.ParetoJourneys(from, to, dep)
+	if len(js) == 0 {
+		return nil
+	}
+	return js[len(js)-1]
+}
+
+// Get all journeys from stop from to stop to departing not before dep
+// which are Pareto-optimal regarding arrival time and number of
+// transfers, sorted by number of transfers
+func (r *Router) ParetoJourneys(from *gtfs.Stop, to *gtfs.Stop, dep int) []*Journey {
+	return r.run([]Access{{from, 0}}, dep, infinity).journeys([]Access{{to, 0}})
+}
+
+// Get all Pareto-optimal journeys between two coordinates, including
+// the walks to and from nearby stops
+func (r *Router) ParetoJourneysBetween(fromLat float64, fromLon float64, toLat float64, toLon float64, dep int) []*Journey {
+	return r.run(r.AccessStops(fromLat, fromLon), dep, infinity).journeys(r.AccessStops(toLat, toLon))
+}
+
+// Get all stops within walking distance of a coordinate
+func (r *Router) AccessStops(lat float64, lon float64) []Access {
+	ret := make([]Access, 0)
+	for _, stop := range r.feed.StopsWithinRadius(lat, lon, r.opts.MaxAccessDist) {
+		d := gtfs.HaversineDist(lat, lon, float64(stop.Lat), float64(stop.Lon))
+		ret = append(ret, Access{stop, int(math.Ceil(d / r.opts.WalkSpeed))})
+	}
+	return ret
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package routing
+
+import (
+	"github.com/geops/gtfsparser/geojson"
+	"github.com/geops/gtfsparser/gtfs"
+	"io"
+	"sort"
+)
+
+// A stop reachable from an origin
+type ReachableStop struct {
+	Stop      *gtfs.Stop
+	Arrival   int // in seconds since the start of the router's service day
+	Duration  int // in seconds since the departure
+	Transfers int
+}
+
+// Get all stops reachable from stop from within maxDur seconds when
+// departing at dep, sorted by arrival
+func (r *Router) Reachable(from *gtfs.Stop, dep int, maxDur int) []ReachableStop {
+	return r.reachable([]Access{{from, 0}}, dep, maxDur)
+}
+
+// Get all stops reachable from a coordinate within maxDur seconds when
+// departing at dep, including the walk to the first stop, sorted by arrival
+func (r *Router) ReachableFrom(lat float64, lon float64, dep int, maxDur int) []ReachableStop {
+	return r.reachable(r.AccessStops(lat, lon), dep, maxDur)
+}
+
+func (r *Router) reachable(origins []Access, dep int, maxDur int) []ReachableStop {
+	s := r.run(origins, dep, dep+maxDur)
+	ret := make([]ReachableStop, 0)
+
+	for p, arr := range s.best {
+		if arr == infinity {
+			continue
+		}
+
+		transfers := s.bestRound[p] - 1
+		if transfers < 0 {
+			transfers = 0
+		}
+
+		ret = append(ret, ReachableStop{
+			Stop:      r.stops[p],
+			Arrival:   arr,
+			Duration:  arr - dep,
+			Transfers: transfers,
+		})
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Arrival < ret[j].Arrival })
+
+	return ret
+}
+
+// Write reachable stops as a GeoJSON FeatureCollection of points
+func WriteReachableGeoJson(w io.Writer, stops []ReachableStop) error {
+	gw := geojson.NewWriter(w)
+
+	for _, rs := range stops {
+		gw.Add("Point", geojson.Position(rs.Stop.Lat, rs.Stop.Lon), map[string]interface{}{
+			"stop_id":   rs.Stop.Id,
+			"stop_name": rs.Stop.Name,
+			"arrival":   gtfs.FormatTime(rs.Arrival),
+			"duration":  rs.Duration,
+			"transfers": rs.Transfers,
+		})
+	}
+
+	return gw.Close()
+}
@@ -0,0 +1,315 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+// Package routing implements journey planning on a parsed GTFS feed
+// using the RAPTOR algorithm.
+package routing
+
+import (
+	"github.com/geops/gtfsparser"
+	"github.com/geops/gtfsparser/gtfs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const infinity = math.MaxInt32
+
+type Options struct {
+	// maximum distance in meters of walking footpaths between stops,
+	// 0 disables walking between stops
+	MaxWalkDist float64
+
+	// maximum walking distance in meters between a coordinate and a stop
+	// for door-to-door queries
+	MaxAccessDist float64
+
+	// walking speed in meters per second
+	WalkSpeed float64
+
+	// minimum time in seconds to change vehicles at the same stop, if
+	// not given in transfers.txt
+	MinChangeTime int
+
+	// maximum number of transfers of a journey
+	MaxTransfers int
+}
+
+// Create new Options with default values
+func NewOptions() *Options {
+	return &Options{
+		MaxWalkDist:   300,
+		MaxAccessDist: 500,
+		WalkSpeed:     1.2,
+		MinChangeTime: 60,
+		MaxTransfers:  5,
+	}
+}
+
+// The times of a single trip at the stops of its pattern, in seconds
+// since the start of the router's service day
+type tripTimes struct {
+	trip    *gtfs.Trip
+	arr     []int
+	dep     []int
+	pickup  []bool
+	dropoff []bool
+}
+
+// A sequence of stops served by trips which do not overtake each other
+type pattern struct {
+	stops []int
+	trips []*tripTimes
+}
+
+type footpath struct {
+	to  int
+	dur int
+}
+
+type patternStop struct {
+	pattern int
+	idx     int
+}
+
+// A Router answers journey planning queries for a single service date
+type Router struct {
+	feed *gtfsparser.Feed
+	date gtfs.Date
+	opts Options
+
+	stops        []*gtfs.Stop
+	stopIdx      map[*gtfs.Stop]int
+	patterns     []*pattern
+	stopPatterns [][]patternStop
+	footpaths    [][]footpath
+	changeTimes  []int
+}
+
+// Create a new Router for all trips of the feed active on date. Trips of
+// the previous service day still running after midnight are included.
+// If opts is nil, default options are used.
+func NewRouter(feed *gtfsparser.Feed, date gtfs.Date, opts *Options) *Router {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	r := &Router{
+		feed:    feed,
+		date:    date,
+		opts:    *opts,
+		stopIdx: make(map[*gtfs.Stop]int),
+	}
+
+	stopIds := make([]string, 0, len(feed.Stops))
+	for id := range feed.Stops {
+		stopIds = append(stopIds, id)
+	}
+	sort.Strings(stopIds)
+
+	for _, id := range stopIds {
+		r.stopIdx[feed.Stops[id]] = len(r.stops)
+		r.stops = append(r.stops, feed.Stops[id])
+	}
+
+	r.buildPatterns()
+	r.buildTransfers()
+
+	return r
+}
+
+func (r *Router) buildPatterns() {
+	tripIds := make([]string, 0, len(r.feed.Trips))
+	for id := range r.feed.Trips {
+		tripIds = append(tripIds, id)
+	}
+	sort.Strings(tripIds)
+
+	all := make([]*tripTimes, 0)
+
+	for _, offset := range []int{-1, 0} {
+		d := r.date.AddDays(offset)
+
+		for _, id := range tripIds {
+			trip := r.feed.Trips[id]
+			if len(trip.StopTimes) < 2 || !trip.Service.IsActiveOn(d) {
+				continue
+			}
+
+			insts := []*gtfs.Trip{trip}
+			if len(trip.Frequencies) > 0 {
+				insts = trip.ExpandFrequencies()
+			}
+
+			for _, inst := range insts {
+				tt := newTripTimes(inst, offset*86400)
+				if tt != nil && tt.arr[len(tt.arr)-1] >= 0 {
+					all = append(all, tt)
+				}
+			}
+		}
+	}
+
+	// with trips added by departure, FIFO only has to be checked
+	// against the last trip of a pattern
+	sort.SliceStable(all, func(i, j int) bool { return all[i].dep[0] < all[j].dep[0] })
+
+	byStops := make(map[string][]int)
+
+	for _, tt := range all {
+		stops := make([]int, len(tt.trip.StopTimes))
+		keys := make([]string, len(tt.trip.StopTimes))
+		for i, st := range tt.trip.StopTimes {
+			stops[i] = r.stopIdx[st.Stop]
+			keys[i] = strconv.Itoa(stops[i])
+		}
+		key := strings.Join(keys, ",")
+
+		added := false
+		for _, pi := range byStops[key] {
+			p := r.patterns[pi]
+			if !overtakes(tt, p.trips[len(p.trips)-1]) {
+				p.trips = append(p.trips, tt)
+				added = true
+				break
+			}
+		}
+
+		if !added {
+			byStops[key] = append(byStops[key], len(r.patterns))
+			r.patterns = append(r.patterns, &pattern{stops: stops, trips: []*tripTimes{tt}})
+		}
+	}
+
+	r.stopPatterns = make([][]patternStop, len(r.stops))
+	for pi, p := range r.patterns {
+		for i, s := range p.stops {
+			r.stopPatterns[s] = append(r.stopPatterns[s], patternStop{pi, i})
+		}
+	}
+}
+
+// Check whether trip a arrives or departs earlier than trip b at any stop
+func overtakes(a *tripTimes, b *tripTimes) bool {
+	for i := range a.arr {
+		if a.arr[i] < b.arr[i] || a.dep[i] < b.dep[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the times of a trip, shifted by offset seconds. The times of
+// untimed stops are interpolated. Returns nil if the trip's first or
+// last stop is untimed.
+func newTripTimes(trip *gtfs.Trip, offset int) *tripTimes {
+	n := len(trip.StopTimes)
+	tt := &tripTimes{
+		trip:    trip,
+		arr:     make([]int, n),
+		dep:     make([]int, n),
+		pickup:  make([]bool, n),
+		dropoff: make([]bool, n),
+	}
+
+	timed := make([]bool, n)
+
+	for i, st := range trip.StopTimes {
+		arr, arrErr := gtfs.ParseTime(st.Arrival_time)
+		dep, depErr := gtfs.ParseTime(st.Departure_time)
+		if arrErr != nil {
+			arr = dep
+		}
+		if depErr != nil {
+			dep = arr
+		}
+		timed[i] = arrErr == nil || depErr == nil
+
+		tt.arr[i] = arr + offset
+		tt.dep[i] = dep + offset
+		tt.pickup[i] = st.Pickup_type != 1 && i < n-1
+		tt.dropoff[i] = st.Drop_off_type != 1 && i > 0
+	}
+
+	if !timed[0] || !timed[n-1] {
+		return nil
+	}
+
+	// interpolate untimed stops linearly by index
+	last := 0
+	for i := 1; i < n; i++ {
+		if !timed[i] {
+			continue
+		}
+		for j := last + 1; j < i; j++ {
+			t := tt.dep[last] + (tt.arr[i]-tt.dep[last])*(j-last)/(i-last)
+			tt.arr[j], tt.dep[j] = t, t
+		}
+		last = i
+	}
+
+	return tt
+}
+
+func (r *Router) buildTransfers() {
+	r.changeTimes = make([]int, len(r.stops))
+	for i := range r.changeTimes {
+		r.changeTimes[i] = r.opts.MinChangeTime
+	}
+
+	type pair struct {
+		from int
+		to   int
+	}
+	explicit := make(map[pair]bool)
+	r.footpaths = make([][]footpath, len(r.stops))
+
+	for _, t := range r.feed.Transfers {
+		from, ok1 := r.stopIdx[t.From_stop]
+		to, ok2 := r.stopIdx[t.To_stop]
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		if from == to {
+			switch t.Transfer_type {
+			case 2:
+				r.changeTimes[from] = t.Min_transfer_time
+			case 3:
+				r.changeTimes[from] = -1
+			}
+			continue
+		}
+
+		explicit[pair{from, to}] = true
+
+		switch t.Transfer_type {
+		case 0, 1:
+			dur := int(math.Ceil(gtfs.HaversineDist(float64(t.From_stop.Lat), float64(t.From_stop.Lon),
+				float64(t.To_stop.Lat), float64(t.To_stop.Lon)) / r.opts.WalkSpeed))
+			r.footpaths[from] = append(r.footpaths[from], footpath{to, dur})
+		case 2:
+			r.footpaths[from] = append(r.footpaths[from], footpath{to, t.Min_transfer_time})
+		}
+	}
+
+	if r.opts.MaxWalkDist <= 0 {
+		return
+	}
+
+	for from, stop := range r.stops {
+		lat, lon := float64(stop.Lat), float64(stop.Lon)
+		for _, near := range r.feed.StopsWithinRadius(lat, lon, r.opts.MaxWalkDist) {
+			to := r.stopIdx[near]
+			if to == from || explicit[pair{from, to}] {
+				continue
+			}
+			dur := int(math.Ceil(gtfs.HaversineDist(lat, lon, float64(near.Lat), float64(near.Lon)) / r.opts.WalkSpeed))
+			r.footpaths[from] = append(r.footpaths[from], footpath{to, dur})
+		}
+	}
+}
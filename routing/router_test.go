@@ -0,0 +1,251 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package routing
+
+import (
+	"github.com/geops/gtfsparser"
+	"github.com/geops/gtfsparser/gtfs"
+	"testing"
+)
+
+var testDate = gtfs.Date{Day: 1, Month: 6, Year: 2020}
+
+// Build a small feed along a line of stops A to F, about 3.8 km apart.
+// D is about 150 m from C, so C and D are connected by a footpath.
+//
+//	T1 (R1): A 08:00, B (untimed), C 08:20
+//	T2 (R2): D 08:30, E 08:40
+//	T3 (R3): B 08:15, F 08:30
+//	T4 (R3): B 08:40, F 08:55
+//	T5 (R4): A 07:50, F 09:30
+func newTestFeed() *gtfsparser.Feed {
+	feed := gtfsparser.NewFeed()
+
+	feed.Agencies["AG"] = &gtfs.Agency{Id: "AG", Name: "Agency", Timezone: "Europe/Berlin"}
+
+	for _, s := range []*gtfs.Stop{
+		{Id: "A", Lat: 47, Lon: 7.00},
+		{Id: "B", Lat: 47, Lon: 7.05},
+		{Id: "C", Lat: 47, Lon: 7.10},
+		{Id: "D", Lat: 47, Lon: 7.102},
+		{Id: "E", Lat: 47, Lon: 7.20},
+		{Id: "F", Lat: 47, Lon: 7.30},
+	} {
+		feed.Stops[s.Id] = s
+	}
+
+	service := &gtfs.Service{
+		Id:         "S",
+		Daymap:     [7]bool{true, true, true, true, true, true, true},
+		Start_date: gtfs.Date{Day: 1, Month: 1, Year: 2020},
+		End_date:   gtfs.Date{Day: 31, Month: 12, Year: 2020},
+	}
+	feed.Services["S"] = service
+
+	for _, id := range []string{"R1", "R2", "R3", "R4"} {
+		feed.Routes[id] = &gtfs.Route{Id: id, Agency: feed.Agencies["AG"], Type: 3}
+	}
+
+	addTrip := func(id string, route string, stops ...string) {
+		trip := &gtfs.Trip{Id: id, Route: feed.Routes[route], Service: service}
+		for i := 0; i < len(stops); i += 2 {
+			trip.StopTimes = append(trip.StopTimes, &gtfs.StopTime{
+				Stop:           feed.Stops[stops[i]],
+				Sequence:       i / 2,
+				Arrival_time:   stops[i+1],
+				Departure_time: stops[i+1],
+			})
+		}
+		feed.Trips[id] = trip
+	}
+
+	addTrip("T1", "R1", "A", "08:00:00", "B", "", "C", "08:20:00")
+	addTrip("T2", "R2", "D", "08:30:00", "E", "08:40:00")
+	addTrip("T3", "R3", "B", "08:15:00", "F", "08:30:00")
+	addTrip("T4", "R3", "B", "08:40:00", "F", "08:55:00")
+	addTrip("T5", "R4", "A", "07:50:00", "F", "09:30:00")
+
+	return feed
+}
+
+func hms(t string) int {
+	secs, err := gtfs.ParseTime(t)
+	if err != nil {
+		panic(err)
+	}
+	return secs
+}
+
+func getTripIds(j *Journey) []string {
+	ret := make([]string, 0)
+	for _, l := range j.Legs {
+		if l.Trip != nil {
+			ret = append(ret, l.Trip.Id)
+		} else {
+			ret = append(ret, "walk")
+		}
+	}
+	return ret
+}
+
+func checkLegs(t *testing.T, j *Journey, want ...string) {
+	t.Helper()
+	got := getTripIds(j)
+	if len(got) != len(want) {
+		t.Fatalf("got legs %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got legs %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEarliestArrival(t *testing.T) {
+	r := NewRouter(newTestFeed(), testDate, nil)
+	feed := r.feed
+
+	j := r.EarliestArrival(feed.Stops["A"], feed.Stops["F"], hms("07:00:00"))
+	if j == nil {
+		t.Fatal("no journey found")
+	}
+
+	if j.Arrival != hms("08:30:00") {
+		t.Errorf("got arrival %s, want 08:30:00", gtfs.FormatTime(j.Arrival))
+	}
+	if j.Transfers != 1 {
+		t.Errorf("got %d transfers, want 1", j.Transfers)
+	}
+	checkLegs(t, j, "T1", "T3")
+
+	// B is untimed on T1 and interpolated halfway between A and C
+	if j.Legs[0].Arrival != hms("08:10:00") {
+		t.Errorf("got interpolated arrival %s at B, want 08:10:00", gtfs.FormatTime(j.Legs[0].Arrival))
+	}
+
+	// departing after T1 and T5, F cannot be reached
+	if j := r.EarliestArrival(feed.Stops["A"], feed.Stops["F"], hms("08:01:00")); j != nil {
+		t.Errorf("got journey %v, want none", getTripIds(j))
+	}
+}
+
+func TestParetoJourneys(t *testing.T) {
+	r := NewRouter(newTestFeed(), testDate, nil)
+	feed := r.feed
+
+	js := r.ParetoJourneys(feed.Stops["A"], feed.Stops["F"], hms("07:00:00"))
+	if len(js) != 2 {
+		t.Fatalf("got %d journeys, want 2", len(js))
+	}
+
+	checkLegs(t, js[0], "T5")
+	if js[0].Transfers != 0 || js[0].Arrival != hms("09:30:00") {
+		t.Errorf("got direct journey with %d transfers arriving %s", js[0].Transfers, gtfs.FormatTime(js[0].Arrival))
+	}
+
+	checkLegs(t, js[1], "T1", "T3")
+}
+
+func TestChangeTimes(t *testing.T) {
+	feed := newTestFeed()
+
+	// T1 arrives at B at 08:10, with a change time of 10 minutes T3 is
+	// missed and T4 is taken instead
+	feed.Transfers = append(feed.Transfers, &gtfs.Transfer{From_stop: feed.Stops["B"], To_stop: feed.Stops["B"], Transfer_type: 2, Min_transfer_time: 600})
+
+	r := NewRouter(feed, testDate, nil)
+	j := r.EarliestArrival(feed.Stops["A"], feed.Stops["F"], hms("07:00:00"))
+	if j == nil {
+		t.Fatal("no journey found")
+	}
+	checkLegs(t, j, "T1", "T4")
+}
+
+func TestOvertakingTrips(t *testing.T) {
+	feed := newTestFeed()
+
+	// T6 departs B after T3 but arrives at F before it, so both cannot
+	// be in the same pattern
+	trip := *feed.Trips["T3"]
+	trip.Id = "T6"
+	trip.StopTimes = gtfs.StopTimes{
+		{Stop: feed.Stops["B"], Sequence: 0, Arrival_time: "08:20:00", Departure_time: "08:20:00"},
+		{Stop: feed.Stops["F"], Sequence: 1, Arrival_time: "08:28:00", Departure_time: "08:28:00"},
+	}
+	feed.Trips["T6"] = &trip
+
+	r := NewRouter(feed, testDate, nil)
+	j := r.EarliestArrival(feed.Stops["A"], feed.Stops["F"], hms("07:00:00"))
+	if j == nil {
+		t.Fatal("no journey found")
+	}
+	checkLegs(t, j, "T1", "T6")
+	if j.Arrival != hms("08:28:00") {
+		t.Errorf("got arrival %s, want 08:28:00", gtfs.FormatTime(j.Arrival))
+	}
+}
+
+func TestTransferNotPossible(t *testing.T) {
+	feed := newTestFeed()
+	feed.Transfers = append(feed.Transfers, &gtfs.Transfer{From_stop: feed.Stops["B"], To_stop: feed.Stops["B"], Transfer_type: 3})
+
+	r := NewRouter(feed, testDate, nil)
+	js := r.ParetoJourneys(feed.Stops["A"], feed.Stops["F"], hms("07:00:00"))
+	if len(js) != 1 {
+		t.Fatalf("got %d journeys, want 1", len(js))
+	}
+	checkLegs(t, js[0], "T5")
+}
+
+func TestWalkAndTrip(t *testing.T) {
+	r := NewRouter(newTestFeed(), testDate, nil)
+	feed := r.feed
+
+	j := r.EarliestArrival(feed.Stops["A"], feed.Stops["E"], hms("07:00:00"))
+	if j == nil {
+		t.Fatal("no journey found")
+	}
+	checkLegs(t, j, "T1", "walk", "T2")
+
+	walk := j.Legs[1]
+	if walk.From != feed.Stops["C"] || walk.To != feed.Stops["D"] {
+		t.Errorf("got walk from %v to %v, want C to D", walk.From, walk.To)
+	}
+	if walk.Departure != hms("08:20:00") || walk.Arrival <= walk.Departure {
+		t.Errorf("got walk from %s to %s", gtfs.FormatTime(walk.Departure), gtfs.FormatTime(walk.Arrival))
+	}
+	if j.Arrival != hms("08:40:00") || j.Transfers != 1 {
+		t.Errorf("got arrival %s with %d transfers, want 08:40:00 with 1", gtfs.FormatTime(j.Arrival), j.Transfers)
+	}
+
+	// without footpaths, E cannot be reached
+	opts := NewOptions()
+	opts.MaxWalkDist = 0
+	r = NewRouter(feed, testDate, opts)
+	if j := r.EarliestArrival(feed.Stops["A"], feed.Stops["E"], hms("07:00:00")); j != nil {
+		t.Errorf("got journey %v, want none", getTripIds(j))
+	}
+}
+
+func TestDoorToDoor(t *testing.T) {
+	r := NewRouter(newTestFeed(), testDate, nil)
+
+	// start about 100 m west of A, end about 100 m east of F
+	js := r.ParetoJourneysBetween(47, 6.9987, 47, 7.3013, hms("07:00:00"))
+	if len(js) == 0 {
+		t.Fatal("no journey found")
+	}
+
+	j := js[len(js)-1]
+	checkLegs(t, j, "walk", "T1", "T3", "walk")
+	if j.Legs[0].From != nil || j.Legs[0].To != r.feed.Stops["A"] {
+		t.Errorf("got access leg from %v to %v, want from the origin to A", j.Legs[0].From, j.Legs[0].To)
+	}
+	if egress := j.Legs[3]; egress.From != r.feed.Stops["F"] || egress.Departure != hms("08:30:00") {
+		t.Errorf("got egress leg from %v at %s, want from F at 08:30:00", egress.From, gtfs.FormatTime(egress.Departure))
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"regexp"
+)
+
+// Keeps only the trips matching all given selectors. Unset selectors
+// match every trip.
+type SelectorFilter struct {
+	// IDs of the agencies to keep
+	Agencies []string
+
+	// route types to keep
+	RouteTypes []int
+
+	// patterns, at least one of which a route ID has to match
+	RouteIds []*regexp.Regexp
+
+	// if set, trips for which this returns false are removed
+	Trips func(trip *gtfs.Trip) bool
+}
+
+// Create a new SelectorFilter keeping only routes of the given types
+func NewRouteTypeFilter(types ...int) *SelectorFilter {
+	return &SelectorFilter{RouteTypes: types}
+}
+
+// Create a new SelectorFilter keeping only routes of the given agencies
+func NewAgencyFilter(agencyIds ...string) *SelectorFilter {
+	return &SelectorFilter{Agencies: agencyIds}
+}
+
+func (f *SelectorFilter) Apply(feed *Feed) *Removed {
+	removed := &Removed{}
+	before := feed.getUsed()
+
+	routes := make(map[*gtfs.Route]bool)
+	for _, route := range feed.Routes {
+		routes[route] = f.matchesRoute(feed, route)
+	}
+
+	for _, trip := range feed.Trips {
+		if !routes[trip.Route] || (f.Trips != nil && !f.Trips(trip)) {
+			feed.removeTrip(trip, removed)
+		}
+	}
+
+	feed.removeOrphans(before, removed)
+
+	return removed
+}
+
+func (f *SelectorFilter) matchesRoute(feed *Feed, route *gtfs.Route) bool {
+	if len(f.Agencies) > 0 {
+		agency := feed.getRouteAgency(route)
+		if agency == nil || !containsString(f.Agencies, agency.Id) {
+			return false
+		}
+	}
+
+	if len(f.RouteTypes) > 0 {
+		found := false
+		for _, t := range f.RouteTypes {
+			if t == route.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.RouteIds) > 0 {
+		found := false
+		for _, re := range f.RouteIds {
+			if re.MatchString(route.Id) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
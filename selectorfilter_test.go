@@ -0,0 +1,68 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"regexp"
+	"testing"
+)
+
+func TestRouteTypeFilter(t *testing.T) {
+	feed := newTestFeed()
+	removed := feed.Filter(NewRouteTypeFilter(0))
+
+	checkIds(t, "trips", getIds(feed.Trips), "T2")
+	checkIds(t, "removed routes", removed.Routes, "R1")
+	checkIds(t, "removed services", removed.Services, "WEEK")
+	checkIds(t, "removed shapes", removed.Shapes, "SH1")
+	checkIds(t, "removed stops", removed.Stops, "S1", "S2")
+	checkIds(t, "removed agencies", removed.Agencies)
+	checkIds(t, "removed fare attributes", removed.FareAttributes, "F1")
+
+	// RX is of type 3 as well, but was unused before
+	checkIds(t, "routes", getIds(feed.Routes), "R2", "RX")
+}
+
+func TestAgencyFilter(t *testing.T) {
+	feed := newTestFeed()
+	removed := feed.Filter(NewAgencyFilter("A1"))
+
+	checkIds(t, "trips", getIds(feed.Trips), "T1")
+	checkIds(t, "removed agencies", removed.Agencies, "A2")
+	checkIds(t, "removed stops", removed.Stops, "S4")
+	checkIds(t, "removed fare attributes", removed.FareAttributes, "F2")
+	checkIds(t, "agencies", getIds(feed.Agencies), "A1", "AX")
+	checkIds(t, "fare attributes", getIds(feed.FareAttributes), "F1", "FX")
+
+	// S3 is still served by T1, so its transfer is kept
+	if removed.Transfers != 0 {
+		t.Errorf("got %d removed transfers, want 0", removed.Transfers)
+	}
+
+	// routes without an agency belong to the feed's only agency
+	feed = newTestFeed()
+	delete(feed.Agencies, "A2")
+	delete(feed.Agencies, "AX")
+	for _, r := range feed.Routes {
+		r.Agency = nil
+	}
+	feed.Filter(NewAgencyFilter("A1"))
+	checkIds(t, "trips", getIds(feed.Trips), "T1", "T2")
+}
+
+func TestSelectorFilter(t *testing.T) {
+	feed := newTestFeed()
+	f := &SelectorFilter{
+		RouteIds: []*regexp.Regexp{regexp.MustCompile("^R[12]$")},
+		Trips:    func(trip *gtfs.Trip) bool { return trip.Service.Id == "WEEK" },
+	}
+	removed := feed.Filter(f)
+
+	checkIds(t, "trips", getIds(feed.Trips), "T1")
+	checkIds(t, "removed trips", removed.Trips, "T2")
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+	"strings"
+)
+
+// Compute the traveled distances in meters of all shape points, and of
+// all stop times by projecting the stops of each trip onto its shape.
+// Existing distances are overwritten. Returns a finding for each stop
+// farther than maxDist meters from the shape of a trip.
+func (feed *Feed) ComputeShapeDistances(maxDist float64) []Finding {
+	findings := make([]Finding, 0)
+
+	for _, shape := range feed.Shapes {
+		shape.Points.ComputeDistTraveled()
+	}
+
+	tripIds := make([]string, 0, len(feed.Trips))
+	for id := range feed.Trips {
+		tripIds = append(tripIds, id)
+	}
+	sort.Strings(tripIds)
+
+	// many trips share the same shape and stops, only project them once
+	type projection struct {
+		pos     []float64
+		offsets []float64
+	}
+	cache := make(map[string]projection)
+
+	for _, id := range tripIds {
+		trip := feed.Trips[id]
+		if trip.Shape == nil || len(trip.StopTimes) == 0 {
+			continue
+		}
+
+		stops := make([]*gtfs.Stop, len(trip.StopTimes))
+		ids := make([]string, len(trip.StopTimes))
+		for i, st := range trip.StopTimes {
+			stops[i] = st.Stop
+			ids[i] = st.Stop.Id
+		}
+
+		key := trip.Shape.Id + "\x00" + strings.Join(ids, "\x00")
+		p, ok := cache[key]
+		if !ok {
+			p.pos, p.offsets = trip.Shape.ProjectStops(stops, maxDist)
+			cache[key] = p
+		}
+
+		for i, st := range trip.StopTimes {
+			st.Shape_dist_traveled = float32(p.pos[i])
+
+			if p.offsets[i] > maxDist {
+				findings = append(findings, Finding{
+					Code:     CodeStopFarFromShape,
+					Severity: SeverityWarning,
+					Filename: "stop_times.txt",
+					Line:     feed.GetLine("stop_times.txt", trip.Id),
+					EntityId: trip.Id,
+					Field:    "stop_id",
+					Msg: fmt.Sprintf("Stop %s is %.0f m away from shape %s of trip %s",
+						st.Stop.Id, p.offsets[i], trip.Shape.Id, trip.Id),
+				})
+			}
+		}
+	}
+
+	return findings
+}
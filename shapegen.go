@@ -0,0 +1,73 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+	"strings"
+)
+
+// Generate straight-line shapes through the stops of all trips without
+// a shape. Trips with identical stop sequences share a shape. Traveled
+// distances of the shape points and stop times are set in meters.
+// Returns the number of shapes generated.
+func (feed *Feed) GenerateShapes() int {
+	tripIds := make([]string, 0, len(feed.Trips))
+	for id, trip := range feed.Trips {
+		if trip.Shape == nil && len(trip.StopTimes) > 1 {
+			tripIds = append(tripIds, id)
+		}
+	}
+	sort.Strings(tripIds)
+
+	shapes := make(map[string]*gtfs.Shape)
+
+	for _, id := range tripIds {
+		trip := feed.Trips[id]
+
+		ids := make([]string, len(trip.StopTimes))
+		for i, st := range trip.StopTimes {
+			ids[i] = st.Stop.Id
+		}
+		key := strings.Join(ids, "\x00")
+
+		shape, ok := shapes[key]
+		if !ok {
+			shape = feed.createStopShape(trip)
+			shapes[key] = shape
+			feed.Shapes[shape.Id] = shape
+		}
+
+		trip.Shape = shape
+		for i, st := range trip.StopTimes {
+			st.Shape_dist_traveled = shape.Points[i].Dist_traveled
+		}
+	}
+
+	if len(shapes) > 0 {
+		feed.InvalidateIndex()
+	}
+
+	return len(shapes)
+}
+
+// Create a shape through the stops of a trip, with an ID not yet used
+func (feed *Feed) createStopShape(trip *gtfs.Trip) *gtfs.Shape {
+	id := "shp_" + trip.Id
+	for _, ok := feed.Shapes[id]; ok; _, ok = feed.Shapes[id] {
+		id = "_" + id
+	}
+
+	shape := &gtfs.Shape{Id: id, Points: make(gtfs.ShapePoints, len(trip.StopTimes))}
+	for i, st := range trip.StopTimes {
+		shape.Points[i] = &gtfs.ShapePoint{Lat: st.Stop.Lat, Lon: st.Stop.Lon, Sequence: i}
+	}
+	shape.Points.ComputeDistTraveled()
+
+	return shape
+}
@@ -0,0 +1,258 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"math"
+	"sort"
+)
+
+const (
+	// grid cell sizes in degrees
+	stopCellSize  = 0.01
+	shapeCellSize = 0.05
+
+	// maximum number of grid cells a single shape segment is added to,
+	// shapes with longer segments are checked on every query
+	maxSegmentCells = 1024
+)
+
+type gridCell struct {
+	x int
+	y int
+}
+
+type spatialIndex struct {
+	stops      map[gridCell][]*gtfs.Stop
+	shapes     map[gridCell][]*gtfs.Shape
+	stopCells  []gridCell
+	shapeCells []gridCell
+	bigShapes  []*gtfs.Shape
+}
+
+func getCell(lat float64, lon float64, size float64) gridCell {
+	return gridCell{int(math.Floor(lon / size)), int(math.Floor(lat / size))}
+}
+
+// Get the lower left and upper right grid cell overlapping a bounding box
+func getCellRange(b gtfs.BBox, size float64) (gridCell, gridCell) {
+	min := getCell(math.Max(b.MinLat, -90), math.Max(b.MinLon, -180), size)
+	max := getCell(math.Min(b.MaxLat, 90), math.Min(b.MaxLon, 180), size)
+	return min, max
+}
+
+// Get all grid cells between min and max. If this would be more cells
+// than the number of occupied cells, only the occupied cells in range
+// are returned.
+func getCells(min gridCell, max gridCell, occupied []gridCell) []gridCell {
+	if max.x < min.x || max.y < min.y {
+		return []gridCell{}
+	}
+
+	n := (max.x - min.x + 1) * (max.y - min.y + 1)
+
+	if n > len(occupied) {
+		cells := make([]gridCell, 0)
+		for _, c := range occupied {
+			if c.x >= min.x && c.x <= max.x && c.y >= min.y && c.y <= max.y {
+				cells = append(cells, c)
+			}
+		}
+		return cells
+	}
+
+	cells := make([]gridCell, 0, n)
+	for x := min.x; x <= max.x; x++ {
+		for y := min.y; y <= max.y; y++ {
+			cells = append(cells, gridCell{x, y})
+		}
+	}
+
+	return cells
+}
+
+func (feed *Feed) buildSpatialIndex() {
+	idx := &spatialIndex{
+		stops:  make(map[gridCell][]*gtfs.Stop),
+		shapes: make(map[gridCell][]*gtfs.Shape),
+	}
+
+	for _, stop := range feed.Stops {
+		c := getCell(float64(stop.Lat), float64(stop.Lon), stopCellSize)
+		idx.stops[c] = append(idx.stops[c], stop)
+	}
+
+	for _, shape := range feed.Shapes {
+		added := make(map[gridCell]bool)
+		big := false
+
+		for i, p := range shape.Points {
+			b := gtfs.BBox{MinLat: float64(p.Lat), MinLon: float64(p.Lon), MaxLat: float64(p.Lat), MaxLon: float64(p.Lon)}
+			if i > 0 {
+				prev := shape.Points[i-1]
+				b.MinLat = math.Min(b.MinLat, float64(prev.Lat))
+				b.MinLon = math.Min(b.MinLon, float64(prev.Lon))
+				b.MaxLat = math.Max(b.MaxLat, float64(prev.Lat))
+				b.MaxLon = math.Max(b.MaxLon, float64(prev.Lon))
+			}
+
+			min, max := getCellRange(b, shapeCellSize)
+			if (max.x-min.x+1)*(max.y-min.y+1) > maxSegmentCells {
+				big = true
+				continue
+			}
+
+			for x := min.x; x <= max.x; x++ {
+				for y := min.y; y <= max.y; y++ {
+					c := gridCell{x, y}
+					if !added[c] {
+						added[c] = true
+						idx.shapes[c] = append(idx.shapes[c], shape)
+					}
+				}
+			}
+		}
+
+		if big {
+			idx.bigShapes = append(idx.bigShapes, shape)
+		}
+	}
+
+	for c := range idx.stops {
+		idx.stopCells = append(idx.stopCells, c)
+	}
+
+	for c := range idx.shapes {
+		idx.shapeCells = append(idx.shapeCells, c)
+	}
+
+	feed.spatialIndex = idx
+}
+
+func (feed *Feed) getSpatialIndex() *spatialIndex {
+	if feed.spatialIndex == nil {
+		feed.buildSpatialIndex()
+	}
+	return feed.spatialIndex
+}
+
+// Get all stops inside a bounding box, sorted by ID
+func (feed *Feed) StopsInBBox(b gtfs.BBox) []*gtfs.Stop {
+	idx := feed.getSpatialIndex()
+	ret := make([]*gtfs.Stop, 0)
+
+	min, max := getCellRange(b, stopCellSize)
+
+	for _, c := range getCells(min, max, idx.stopCells) {
+		for _, stop := range idx.stops[c] {
+			if b.Contains(float64(stop.Lat), float64(stop.Lon)) {
+				ret = append(ret, stop)
+			}
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Id < ret[j].Id })
+
+	return ret
+}
+
+// Get all stops within radius meters of a coordinate, sorted by distance
+func (feed *Feed) StopsWithinRadius(lat float64, lon float64, radius float64) []*gtfs.Stop {
+	dists := make(map[*gtfs.Stop]float64)
+	ret := make([]*gtfs.Stop, 0)
+
+	// the box may cross the antimeridian
+	for _, b := range gtfs.RadiusBBox(lat, lon, radius).SplitAtAntimeridian() {
+		for _, stop := range feed.StopsInBBox(b) {
+			if _, ok := dists[stop]; ok {
+				continue
+			}
+			d := gtfs.HaversineDist(lat, lon, float64(stop.Lat), float64(stop.Lon))
+			if d <= radius {
+				dists[stop] = d
+				ret = append(ret, stop)
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if dists[ret[i]] != dists[ret[j]] {
+			return dists[ret[i]] < dists[ret[j]]
+		}
+		return ret[i].Id < ret[j].Id
+	})
+
+	return ret
+}
+
+// Get the k stops nearest to a coordinate, sorted by distance
+func (feed *Feed) NearestStops(lat float64, lon float64, k int) []*gtfs.Stop {
+	if k <= 0 || len(feed.Stops) == 0 {
+		return []*gtfs.Stop{}
+	}
+
+	// grow the search radius until enough stops were found
+	radius := 500.0
+	for {
+		ret := feed.StopsWithinRadius(lat, lon, radius)
+		if len(ret) >= k {
+			return ret[:k]
+		}
+		if radius > math.Pi*gtfs.EarthRadius {
+			return ret
+		}
+		radius *= 4
+	}
+}
+
+// Get all shapes with at least one segment inside a bounding box,
+// sorted by ID
+func (feed *Feed) ShapesIntersectingBBox(b gtfs.BBox) []*gtfs.Shape {
+	idx := feed.getSpatialIndex()
+	checked := make(map[*gtfs.Shape]bool)
+	ret := make([]*gtfs.Shape, 0)
+
+	cands := make([]*gtfs.Shape, 0)
+	min, max := getCellRange(b, shapeCellSize)
+
+	for _, c := range getCells(min, max, idx.shapeCells) {
+		cands = append(cands, idx.shapes[c]...)
+	}
+	cands = append(cands, idx.bigShapes...)
+
+	for _, shape := range cands {
+		if checked[shape] {
+			continue
+		}
+		checked[shape] = true
+
+		if shapeIntersectsBBox(shape, b) {
+			ret = append(ret, shape)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Id < ret[j].Id })
+
+	return ret
+}
+
+func shapeIntersectsBBox(shape *gtfs.Shape, b gtfs.BBox) bool {
+	pts := shape.Points
+
+	if len(pts) == 1 {
+		return b.Contains(float64(pts[0].Lat), float64(pts[0].Lon))
+	}
+
+	for i := 1; i < len(pts); i++ {
+		if b.IntersectsSegment(float64(pts[i-1].Lat), float64(pts[i-1].Lon), float64(pts[i].Lat), float64(pts[i].Lon)) {
+			return true
+		}
+	}
+
+	return false
+}
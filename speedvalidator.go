@@ -0,0 +1,133 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+)
+
+const (
+	CodeFastTravel = "fast_travel"
+	CodeTeleport   = "teleport"
+)
+
+// Checks the travel speed implied by consecutive stop times of each trip
+type SpeedValidator struct {
+	// maximum plausible speed in km/h, keyed by route type
+	MaxSpeeds map[int]float64
+
+	// maximum speed in km/h for route types not in MaxSpeeds
+	DefaultMaxSpeed float64
+
+	// maximum distance in meters between two stops served at the same time
+	MaxZeroTimeDist float64
+}
+
+// Create a new SpeedValidator with default thresholds
+func NewSpeedValidator() *SpeedValidator {
+	return &SpeedValidator{
+		MaxSpeeds: map[int]float64{
+			0: 100, // tram
+			1: 150, // subway
+			2: 500, // rail
+			3: 150, // bus
+			4: 80,  // ferry
+			5: 30,  // cable car
+			6: 50,  // gondola
+			7: 50,  // funicular
+		},
+		DefaultMaxSpeed: 500,
+		MaxZeroTimeDist: 2000,
+	}
+}
+
+func (v *SpeedValidator) Validate(feed *Feed) []Finding {
+	findings := make([]Finding, 0)
+
+	// iterate in a stable order to get reproducible reports
+	ids := make([]string, 0, len(feed.Trips))
+	for id := range feed.Trips {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		findings = append(findings, v.validateTrip(feed.Trips[id])...)
+	}
+
+	return findings
+}
+
+func (v *SpeedValidator) validateTrip(trip *gtfs.Trip) []Finding {
+	findings := make([]Finding, 0)
+
+	maxSpeed := v.DefaultMaxSpeed
+	if trip.Route != nil {
+		if val, ok := v.MaxSpeeds[trip.Route.Type]; ok {
+			maxSpeed = val
+		}
+	}
+
+	var last *gtfs.StopTime
+	lastDep := 0
+	dist := 0.0
+
+	for i, st := range trip.StopTimes {
+		if i > 0 {
+			prev := trip.StopTimes[i-1].Stop
+			dist += gtfs.HaversineDist(float64(prev.Lat), float64(prev.Lon), float64(st.Stop.Lat), float64(st.Stop.Lon))
+		}
+
+		arr, arrErr := gtfs.ParseTime(st.Arrival_time)
+		dep, depErr := gtfs.ParseTime(st.Departure_time)
+
+		// untimed stop, accumulate the distance up to the next timed stop
+		if arrErr != nil && depErr != nil {
+			continue
+		}
+		if arrErr != nil {
+			arr = dep
+		}
+		if depErr != nil {
+			dep = arr
+		}
+
+		if last != nil {
+			dt := arr - lastDep
+
+			if dt <= 0 && dist > v.MaxZeroTimeDist {
+				findings = append(findings, Finding{
+					Code:     CodeTeleport,
+					Severity: SeverityWarning,
+					Filename: "stop_times.txt",
+					EntityId: trip.Id,
+					Field:    "arrival_time",
+					Msg: fmt.Sprintf("Trip %s travels %.0f m from stop %s to stop %s in no time",
+						trip.Id, dist, last.Stop.Id, st.Stop.Id),
+				})
+			} else if dt > 0 && dist/float64(dt)*3.6 > maxSpeed {
+				findings = append(findings, Finding{
+					Code:     CodeFastTravel,
+					Severity: SeverityWarning,
+					Filename: "stop_times.txt",
+					EntityId: trip.Id,
+					Field:    "arrival_time",
+					Msg: fmt.Sprintf("Trip %s travels from stop %s to stop %s at %.0f km/h, expected at most %.0f km/h",
+						trip.Id, last.Stop.Id, st.Stop.Id, dist/float64(dt)*3.6, maxSpeed),
+				})
+			}
+		}
+
+		last = st
+		lastDep = dep
+		dist = 0
+	}
+
+	return findings
+}
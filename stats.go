@@ -0,0 +1,229 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/geops/gtfsparser/gtfs"
+	"sort"
+)
+
+// distance in meters up to which positions on a shape are considered
+// when projecting stops onto it
+const maxStopShapeDist = 150
+
+// Service metrics of a set of trips over a date range. Times are given
+// in seconds since the start of the service day.
+type ServiceStats struct {
+	Trips          int // number of trip instances, counted once per active day
+	RevenueSecs    int
+	RevenueMeters  float64
+	FirstDeparture int // -1 if there are no trips
+	LastDeparture  int // -1 if there are no trips
+
+	// sum and count of headways between consecutive trips of the same
+	// route and direction, by hour of departure
+	headwaySums   map[int]int
+	headwayCounts map[int]int
+}
+
+func newServiceStats() *ServiceStats {
+	return &ServiceStats{
+		FirstDeparture: -1,
+		LastDeparture:  -1,
+		headwaySums:    make(map[int]int),
+		headwayCounts:  make(map[int]int),
+	}
+}
+
+// Get the revenue hours
+func (s *ServiceStats) RevenueHours() float64 {
+	return float64(s.RevenueSecs) / 3600
+}
+
+// Get the revenue kilometers
+func (s *ServiceStats) RevenueKm() float64 {
+	return s.RevenueMeters / 1000
+}
+
+// Get the average headway in seconds of trips departing in the given
+// hour of the service day, or 0 if there are less than two trips
+func (s *ServiceStats) AvgHeadway(hour int) float64 {
+	if s.headwayCounts[hour] == 0 {
+		return 0
+	}
+	return float64(s.headwaySums[hour]) / float64(s.headwayCounts[hour])
+}
+
+// Get the hours of the service day with a known average headway, sorted
+func (s *ServiceStats) HeadwayHours() []int {
+	ret := make([]int, 0, len(s.headwayCounts))
+	for h := range s.headwayCounts {
+		ret = append(ret, h)
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+func (s *ServiceStats) add(o *ServiceStats) {
+	s.Trips += o.Trips
+	s.RevenueSecs += o.RevenueSecs
+	s.RevenueMeters += o.RevenueMeters
+	if o.FirstDeparture >= 0 && (s.FirstDeparture < 0 || o.FirstDeparture < s.FirstDeparture) {
+		s.FirstDeparture = o.FirstDeparture
+	}
+	if o.LastDeparture > s.LastDeparture {
+		s.LastDeparture = o.LastDeparture
+	}
+	for h, v := range o.headwaySums {
+		s.headwaySums[h] += v
+		s.headwayCounts[h] += o.headwayCounts[h]
+	}
+}
+
+// Get service metrics for each route, for all days between from and to
+// (inclusive). Frequency-based trips are counted per trip instance.
+func (feed *Feed) GetRouteStats(from gtfs.Date, to gtfs.Date) map[*gtfs.Route]*ServiceStats {
+	ret := make(map[*gtfs.Route]*ServiceStats)
+
+	for _, route := range feed.Routes {
+		stats := newServiceStats()
+		trips := feed.TripsForRoute(route)
+
+		// trip instances are computed once, and counted per active day
+		insts := make(map[*gtfs.Trip][]tripInstance, len(trips))
+		for _, trip := range trips {
+			insts[trip] = getTripInstances(trip)
+		}
+
+		for d := from; !d.After(to); d = d.AddDays(1) {
+			deps := make(map[int][]int)
+
+			for _, trip := range trips {
+				if !trip.Service.IsActiveOn(d) {
+					continue
+				}
+				for _, inst := range insts[trip] {
+					stats.Trips++
+					stats.RevenueSecs += inst.arr - inst.dep
+					stats.RevenueMeters += inst.dist
+					if stats.FirstDeparture < 0 || inst.dep < stats.FirstDeparture {
+						stats.FirstDeparture = inst.dep
+					}
+					if inst.dep > stats.LastDeparture {
+						stats.LastDeparture = inst.dep
+					}
+					deps[trip.Direction_id] = append(deps[trip.Direction_id], inst.dep)
+				}
+			}
+
+			for _, times := range deps {
+				sort.Ints(times)
+				for i := 1; i < len(times); i++ {
+					stats.headwaySums[times[i]/3600] += times[i] - times[i-1]
+					stats.headwayCounts[times[i]/3600]++
+				}
+			}
+		}
+
+		ret[route] = stats
+	}
+
+	return ret
+}
+
+// Get service metrics for each agency, for all days between from and to
+// (inclusive). Headways are only computed between trips of the same route.
+func (feed *Feed) GetAgencyStats(from gtfs.Date, to gtfs.Date) map[*gtfs.Agency]*ServiceStats {
+	ret := make(map[*gtfs.Agency]*ServiceStats)
+
+	for _, agency := range feed.Agencies {
+		ret[agency] = newServiceStats()
+	}
+
+	routeStats := feed.GetRouteStats(from, to)
+	for agency := range ret {
+		for _, route := range feed.RoutesForAgency(agency) {
+			ret[agency].add(routeStats[route])
+		}
+	}
+
+	return ret
+}
+
+// A single run of a trip, in seconds since the start of the service day
+type tripInstance struct {
+	dep  int
+	arr  int
+	dist float64
+}
+
+// Get all runs of a trip, which are more than one for frequency-based trips
+func getTripInstances(trip *gtfs.Trip) []tripInstance {
+	if len(trip.StopTimes) == 0 {
+		return nil
+	}
+
+	dep, err := getFirstDeparture(trip)
+	if err != nil {
+		return nil
+	}
+
+	last := trip.StopTimes[len(trip.StopTimes)-1]
+	arr, err := gtfs.ParseTime(last.Arrival_time)
+	if err != nil {
+		if arr, err = gtfs.ParseTime(last.Departure_time); err != nil {
+			return nil
+		}
+	}
+
+	dist := getTripLength(trip)
+
+	if len(trip.Frequencies) == 0 {
+		return []tripInstance{{dep, arr, dist}}
+	}
+
+	ret := make([]tripInstance, 0)
+	for _, f := range trip.Frequencies {
+		for _, start := range f.GetStartTimes() {
+			ret = append(ret, tripInstance{start, start + arr - dep, dist})
+		}
+	}
+
+	return ret
+}
+
+// Get the length of a trip in meters, along the part of its shape
+// between its first and last stop if it has one, or else as the sum of
+// the distances between its stops
+func getTripLength(trip *gtfs.Trip) float64 {
+	if trip.Shape != nil && len(trip.Shape.Points) > 1 && len(trip.StopTimes) > 1 {
+		pts := trip.Shape.Points
+		first, last := trip.StopTimes[0], trip.StopTimes[len(trip.StopTimes)-1]
+
+		// traveled distances may be given in any unit, so they are only
+		// used as a fraction of the distance traveled along the shape
+		if total := pts[len(pts)-1].Dist_traveled - pts[0].Dist_traveled; total > 0 && last.Shape_dist_traveled > first.Shape_dist_traveled {
+			return pts.Length() * float64(last.Shape_dist_traveled-first.Shape_dist_traveled) / float64(total)
+		}
+
+		stops := make([]*gtfs.Stop, len(trip.StopTimes))
+		for i, st := range trip.StopTimes {
+			stops[i] = st.Stop
+		}
+		pos, _ := trip.Shape.ProjectStops(stops, maxStopShapeDist)
+
+		return pos[len(pos)-1] - pos[0]
+	}
+
+	dist := 0.0
+	for i := 1; i < len(trip.StopTimes); i++ {
+		a, b := trip.StopTimes[i-1].Stop, trip.StopTimes[i].Stop
+		dist += gtfs.HaversineDist(float64(a.Lat), float64(a.Lon), float64(b.Lat), float64(b.Lon))
+	}
+
+	return dist
+}
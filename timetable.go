@@ -0,0 +1,258 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"encoding/csv"
+	"github.com/geops/gtfsparser/gtfs"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A stop × trip matrix of the trips of a route in one direction
+type Timetable struct {
+	Route        *gtfs.Route
+	Direction_id int
+	Dates        []gtfs.Date // the dates the timetable is valid on
+
+	// rows, in an order consistent with the stop order of all trips. A
+	// stop served twice by a trip appears in two rows.
+	Stops []*gtfs.Stop
+
+	// columns, sorted by departure at the first stop
+	Trips []*gtfs.Trip
+
+	// Times[i][j] is the stop time of trip j at row i, or nil if the
+	// trip does not serve the stop
+	Times [][]*gtfs.StopTime
+}
+
+// Get the timetable of a route in one direction on a single date.
+// Frequency-based trips are expanded into their trip instances.
+func (feed *Feed) GetTimetable(route *gtfs.Route, direction int, date gtfs.Date) *Timetable {
+	return feed.GetTimetables(route, direction, []gtfs.Date{date})[0]
+}
+
+// Get the timetables of a route in one direction on several dates.
+// Dates with an identical set of active trips share a timetable. The
+// timetables are sorted by their first date.
+func (feed *Feed) GetTimetables(route *gtfs.Route, direction int, dates []gtfs.Date) []*Timetable {
+	byTrips := make(map[string]*Timetable)
+	ret := make([]*Timetable, 0)
+
+	sorted := make([]gtfs.Date, len(dates))
+	copy(sorted, dates)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	candidates := make([]*gtfs.Trip, 0)
+	for _, trip := range feed.TripsForRoute(route) {
+		if trip.Direction_id == direction && len(trip.StopTimes) > 0 {
+			candidates = append(candidates, trip)
+		}
+	}
+
+	for _, d := range sorted {
+		ids := make([]string, 0)
+		active := make([]*gtfs.Trip, 0)
+		for _, trip := range candidates {
+			if trip.Service.IsActiveOn(d) {
+				ids = append(ids, trip.Id)
+				active = append(active, trip)
+			}
+		}
+
+		key := strings.Join(ids, "\x00")
+		if tt, ok := byTrips[key]; ok {
+			tt.Dates = append(tt.Dates, d)
+			continue
+		}
+
+		tt := newTimetable(route, direction, active)
+		tt.Dates = []gtfs.Date{d}
+		byTrips[key] = tt
+		ret = append(ret, tt)
+	}
+
+	return ret
+}
+
+// A row of a timetable: the n-th visit of a stop by a trip
+type ttNode struct {
+	stop *gtfs.Stop
+	n    int
+}
+
+func newTimetable(route *gtfs.Route, direction int, trips []*gtfs.Trip) *Timetable {
+	tt := &Timetable{Route: route, Direction_id: direction}
+
+	for _, trip := range trips {
+		if len(trip.Frequencies) > 0 {
+			if insts := trip.ExpandFrequencies(); insts != nil {
+				tt.Trips = append(tt.Trips, insts...)
+				continue
+			}
+		}
+		tt.Trips = append(tt.Trips, trip)
+	}
+
+	sortTripsByDeparture(tt.Trips)
+
+	// the rows of each trip's stop times
+	tripNodes := make([][]ttNode, len(tt.Trips))
+	for j, trip := range tt.Trips {
+		visits := make(map[*gtfs.Stop]int)
+		tripNodes[j] = make([]ttNode, len(trip.StopTimes))
+		for i, st := range trip.StopTimes {
+			tripNodes[j][i] = ttNode{st.Stop, visits[st.Stop]}
+			visits[st.Stop]++
+		}
+	}
+
+	order := mergeStopSequences(tt.Trips, tripNodes)
+
+	rows := make(map[ttNode]int, len(order))
+	for i, node := range order {
+		rows[node] = i
+		tt.Stops = append(tt.Stops, node.stop)
+	}
+
+	tt.Times = make([][]*gtfs.StopTime, len(order))
+	for i := range tt.Times {
+		tt.Times[i] = make([]*gtfs.StopTime, len(tt.Trips))
+	}
+
+	for j, trip := range tt.Trips {
+		for i, st := range trip.StopTimes {
+			tt.Times[rows[tripNodes[j][i]]][j] = st
+		}
+	}
+
+	return tt
+}
+
+// Merge the stop sequences of trips into a single order by a topological
+// sort. Ties are broken by the position of a stop in the most frequent
+// stop sequence. If the sequences contradict each other, the order of
+// the less frequent sequences is not preserved.
+func mergeStopSequences(trips []*gtfs.Trip, tripNodes [][]ttNode) []ttNode {
+	// count the trips per stop sequence
+	type seq struct {
+		nodes []ttNode
+		count int
+		first int
+	}
+	seqs := make(map[string]*seq)
+	for j := range trips {
+		parts := make([]string, len(tripNodes[j]))
+		for i, n := range tripNodes[j] {
+			parts[i] = n.stop.Id
+		}
+		key := strings.Join(parts, "\x00")
+		if s, ok := seqs[key]; ok {
+			s.count++
+		} else {
+			seqs[key] = &seq{tripNodes[j], 1, j}
+		}
+	}
+
+	sorted := make([]*seq, 0, len(seqs))
+	for _, s := range seqs {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].first < sorted[j].first
+	})
+
+	// priority of each node, lower is earlier
+	prio := make(map[ttNode]int)
+	succs := make(map[ttNode]map[ttNode]bool)
+	indeg := make(map[ttNode]int)
+
+	for si, s := range sorted {
+		for i, n := range s.nodes {
+			if _, ok := prio[n]; !ok {
+				prio[n] = si*1000000 + i
+				succs[n] = make(map[ttNode]bool)
+			}
+			if i > 0 && !succs[s.nodes[i-1]][n] {
+				succs[s.nodes[i-1]][n] = true
+				indeg[n]++
+			}
+		}
+	}
+
+	order := make([]ttNode, 0, len(prio))
+	done := make(map[ttNode]bool)
+
+	for len(order) < len(prio) {
+		// the free node with the lowest priority, or if the sequences
+		// contain a cycle, the remaining node with the lowest priority
+		var next ttNode
+		best, bestFree := -1, false
+		for n, p := range prio {
+			if done[n] {
+				continue
+			}
+			free := indeg[n] == 0
+			if best < 0 || (free && !bestFree) || (free == bestFree && p < best) {
+				next, best, bestFree = n, p, free
+			}
+		}
+
+		done[next] = true
+		order = append(order, next)
+		for s := range succs[next] {
+			if !done[s] {
+				indeg[s]--
+			}
+		}
+	}
+
+	return order
+}
+
+// Write the timetable as CSV, with one row per stop and one column per
+// trip. Each cell holds the departure time, or the arrival time if no
+// departure time is given, in HH:MM:SS format.
+func (tt *Timetable) WriteCsv(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"stop_id", "stop_name"}
+	for _, trip := range tt.Trips {
+		header = append(header, trip.Id)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for i, stop := range tt.Stops {
+		row := []string{stop.Id, stop.Name}
+		for _, st := range tt.Times[i] {
+			cell := ""
+			if st != nil {
+				cell = st.Departure_time
+				if len(cell) == 0 {
+					cell = st.Arrival_time
+				}
+				if t, err := gtfs.ParseTime(cell); err == nil {
+					cell = gtfs.FormatTime(t)
+				}
+			}
+			row = append(row, cell)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
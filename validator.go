@@ -0,0 +1,71 @@
+// Copyright 2015 geOps
+// Authors: patrick.brosi@geops.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// Get a string representation of a Severity
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// A single problem found in a feed by a Validator
+type Finding struct {
+	Code     string // stable rule code, e.g. "fast_travel"
+	Severity Severity
+	Filename string
+	Line     int // 0 if unknown
+	EntityId string
+	Field    string
+	Msg      string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d - [%s] %s: %s", f.Filename, f.Line, f.Severity, f.Code, f.Msg)
+}
+
+// A Validator checks an already parsed feed for semantic problems
+// that cannot be detected while reading a single record
+type Validator interface {
+	Validate(feed *Feed) []Finding
+}
+
+// Run the given validators on the feed and collect their findings
+func (feed *Feed) Validate(validators ...Validator) []Finding {
+	findings := make([]Finding, 0)
+
+	for _, v := range validators {
+		findings = append(findings, v.Validate(feed)...)
+	}
+
+	// locate findings in their files
+	for i := range findings {
+		if findings[i].Line == 0 && len(findings[i].EntityId) > 0 {
+			findings[i].Line = feed.GetLine(findings[i].Filename, findings[i].EntityId)
+		}
+	}
+
+	return findings
+}